@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the config file in the repository root.
+const FileName = ".changed-objects.yaml"
+
+type Config struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile is a set of options keyed by the long names of the flags, e.g.
+// "default-branch" or "group-by".
+type Profile map[string]interface{}
+
+func Load(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("cannot read config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return Config{}, fmt.Errorf("cannot parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+func (c Config) Profile(name string) (Profile, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for name := range c.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%s: no such profile in config (available: %s)", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
+
+// Names returns the option names in the profile in sorted order.
+func (p Profile) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Flag tells how to pass an option on the command line.
+type Flag struct {
+	// Bool is true if the option takes no argument
+	Bool bool
+	// Skip is true if the option should be left out, e.g. it's given on the
+	// command line
+	Skip bool
+}
+
+// Args converts the profile to command line arguments. flag tells how to pass
+// each option, or returns an error for an unknown option.
+func (p Profile) Args(flag func(name string) (Flag, error)) ([]string, error) {
+	var args []string
+	for _, name := range p.Names() {
+		f, err := flag(name)
+		if err != nil {
+			return nil, err
+		}
+		if f.Skip {
+			continue
+		}
+		if f.Bool {
+			switch value := p[name].(type) {
+			case bool:
+				if value {
+					args = append(args, "--"+name)
+				}
+			default:
+				return nil, fmt.Errorf("%s: value must be a boolean", name)
+			}
+			continue
+		}
+		switch value := p[name].(type) {
+		case nil:
+		case []interface{}:
+			for _, v := range value {
+				s, err := scalar(v)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				args = append(args, fmt.Sprintf("--%s=%s", name, s))
+			}
+		default:
+			s, err := scalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			args = append(args, fmt.Sprintf("--%s=%s", name, s))
+		}
+	}
+	return args, nil
+}
+
+func scalar(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, int, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", errors.New("value must be a string, a number, a boolean or a list of them")
+	}
+}
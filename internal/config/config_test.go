@@ -0,0 +1,68 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProfile_Args(t *testing.T) {
+	profile := Profile{
+		"default-branch": "master",
+		"type":           []interface{}{"added", "modified"},
+		"group-by":       []interface{}{"terraform/**/{dev,prod}"},
+		"dir-exist":      true,
+		"find-renames":   80,
+		"worktree":       false,
+		"untracked":      true,
+	}
+
+	cases := []struct {
+		name string
+		skip map[string]bool
+		want []string
+	}{
+		{
+			name: "all",
+			skip: map[string]bool{},
+			want: []string{
+				"--default-branch=master",
+				"--dir-exist=true",
+				"--find-renames=80",
+				"--group-by=terraform/**/{dev,prod}",
+				"--type=added",
+				"--type=modified",
+				"--untracked",
+			},
+		},
+		{
+			name: "skip given ones",
+			skip: map[string]bool{"type": true, "default-branch": true},
+			want: []string{
+				"--dir-exist=true",
+				"--find-renames=80",
+				"--group-by=terraform/**/{dev,prod}",
+				"--untracked",
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := profile.Args(func(name string) (Flag, error) {
+				return Flag{
+					Bool: name == "worktree" || name == "untracked",
+					Skip: tt.skip[name],
+				}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package detect
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// captureRe matches a named segment of group-by patterns such as {:service},
+// which matches any single path component like "*". The colon tells it from
+// alternatives such as {prod} and {dev,prod}.
+var captureRe = regexp.MustCompile(`^\{:([A-Za-z_][A-Za-z0-9_-]*)\}$`)
+
+// ExpandCaptures returns the pattern whose named segments are replaced with
+// "*" so that it can be matched with doublestar.
+func ExpandCaptures(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if captureRe.MatchString(segment) {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func expandAllCaptures(patterns []string) []string {
+	globs := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		globs = append(globs, ExpandCaptures(pattern))
+	}
+	return globs
+}
+
+// captureLabels returns the path components captured by the named segments
+// of the pattern. It returns false if the pattern doesn't match the path.
+func captureLabels(pattern, path string) (map[string]string, bool) {
+	labels := make(map[string]string)
+	if !matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"), labels) {
+		return nil, false
+	}
+	return labels, true
+}
+
+func matchSegments(pattern, path []string, labels map[string]string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	if pattern[0] == "**" {
+		// "**" matches zero or more path components
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:], labels) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+	if m := captureRe.FindStringSubmatch(pattern[0]); m != nil {
+		if !matchSegments(pattern[1:], path[1:], labels) {
+			return false
+		}
+		labels[m[1]] = path[0]
+		return true
+	}
+	if matched, _ := doublestar.Match(pattern[0], path[0]); !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:], labels)
+}
+
+// getLabels returns the labels of the dir captured by the first pattern
+// which has named segments and matches the dir.
+func getLabels(patterns []string, path string) map[string]string {
+	for _, pattern := range patterns {
+		labels, ok := captureLabels(pattern, path)
+		if ok && len(labels) > 0 {
+			return labels
+		}
+	}
+	return nil
+}
+
+// mergeLabels adds the labels which the dir doesn't have yet, so that labels
+// captured by patterns take precedence.
+func mergeLabels(labels, added map[string]string) map[string]string {
+	if len(added) == 0 {
+		return labels
+	}
+	if labels == nil {
+		labels = make(map[string]string, len(added))
+	}
+	for name, value := range added {
+		if _, ok := labels[name]; !ok {
+			labels[name] = value
+		}
+	}
+	return labels
+}
@@ -0,0 +1,175 @@
+package detect
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/b4b4r07/changed-objects/internal/git"
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/samber/lo"
+)
+
+// dependent is a dir which isn't changed itself but depends on changed
+// files, e.g. a root module calling a changed Terraform module.
+type dependent struct {
+	path    string
+	reason  string
+	changes []git.Change
+}
+
+// analyzer finds dependents of the changes by reading the repository.
+type analyzer interface {
+	dependents(changes []git.Change) ([]dependent, error)
+}
+
+// labeler is an analyzer which also labels dirs, e.g. with the environments
+// of a Helm chart.
+type labeler interface {
+	labels(dir Dir) map[string]string
+}
+
+// newAnalyzer returns the analyzer of the name, which reads the files being
+// compared from fsys.
+func newAnalyzer(name string, fsys fs.FS) (analyzer, error) {
+	switch name {
+	case "terraform":
+		return terraform{fsys: fsys}, nil
+	case "kustomize":
+		return kustomize{fsys: fsys}, nil
+	case "helm":
+		return helm{fsys: fsys}, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported analyzer", name)
+	}
+}
+
+// walkRepo calls fn with the path relative to the repo of each file in the
+// repo. The .git dir and the dirs with the given names are skipped.
+func walkRepo(fsys fs.FS, fn func(rel string) error, skipDirs ...string) error {
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != "." && (d.Name() == ".git" || lo.Contains(skipDirs, d.Name())) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		return fn(filepath.FromSlash(path))
+	})
+}
+
+// joinRepoPath joins the path referenced from the dir to it. It returns false
+// if the path is out of the repo.
+func joinRepoPath(dir, path string) (string, bool) {
+	target := filepath.Join(dir, path)
+	if target == ".." || strings.HasPrefix(target, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return target, true
+}
+
+// graph is a reverse dependency graph of dirs, which maps a dir to the dirs
+// depending on it.
+type graph map[string][]string
+
+func (g graph) add(from, to string) {
+	for _, dir := range g[to] {
+		if dir == from {
+			return
+		}
+	}
+	g[to] = append(g[to], from)
+}
+
+// dependents returns the dirs which depend on the changed dir directly or
+// transitively with the reasons, e.g. "calls module a which calls changed
+// module b". If rootsOnly, the dirs which other dirs depend on are left out.
+func (g graph) dependents(changed, verb string, noun func(dir string) string, rootsOnly bool) map[string]string {
+	found := make(map[string]string)
+	chains := map[string]string{changed: "changed " + noun(changed)}
+	queue := []string{changed}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		for _, from := range g[dir] {
+			if _, ok := chains[from]; ok {
+				continue
+			}
+			chains[from] = fmt.Sprintf("%s which %s %s", noun(from), verb, chains[dir])
+			if !rootsOnly || len(g[from]) == 0 {
+				found[from] = verb + " " + chains[dir]
+			}
+			queue = append(queue, from)
+		}
+	}
+	return found
+}
+
+// groupDependents returns the dependents in a group of the patterns. As with
+// changed files, a dependent belongs to the shallowest dir matching them.
+func groupDependents(deps []dependent, patterns []string) []dependent {
+	var grouped []dependent
+	for _, dep := range deps {
+		steps := getSteps(dep.path)
+		for i := len(steps) - 1; i >= 0; i-- {
+			if lo.SomeBy(patterns, func(pattern string) bool {
+				matched, _ := doublestar.Match(pattern, steps[i])
+				return matched
+			}) {
+				dep.path = steps[i]
+				grouped = append(grouped, dep)
+				break
+			}
+		}
+	}
+	return grouped
+}
+
+// addDependents adds the dependents to the dirs unless the dirs already have
+// them. The dependents of the same dir are merged into one.
+func addDependents(dirs []Dir, dependents []dependent) []Dir {
+	seen := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		seen[dir.Path] = true
+	}
+
+	sort.SliceStable(dependents, func(i, j int) bool {
+		return dependents[i].path < dependents[j].path
+	})
+	index := make(map[string]int)
+	for _, dep := range dependents {
+		if seen[dep.path] {
+			continue
+		}
+		files := make([]File, 0, len(dep.changes))
+		for _, change := range dep.changes {
+			files = append(files, getFile(change))
+		}
+		if i, ok := index[dep.path]; ok {
+			dirs[i].Reason += "; " + dep.reason
+			for _, file := range files {
+				if !lo.ContainsBy(dirs[i].Files, func(f File) bool { return f.Path == file.Path }) {
+					dirs[i].Files = append(dirs[i].Files, file)
+				}
+			}
+			continue
+		}
+		index[dep.path] = len(dirs)
+		dirs = append(dirs, Dir{
+			Path: dep.path,
+			Exist: func() bool {
+				_, err := os.Stat(dep.path)
+				return err == nil
+			}(),
+			Files:  files,
+			Reason: dep.reason,
+		})
+	}
+	return dirs
+}
@@ -1,6 +1,8 @@
 package detect
 
 import (
+	"errors"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -13,49 +15,127 @@ import (
 )
 
 type client struct {
-	args    []string
-	opt     Option
-	changes []git.Change
-	pp      *pp.PrettyPrinter
+	repo       string
+	fsys       fs.FS
+	args       []string
+	opt        Option
+	changes    []git.Change
+	comparison Comparison
+	ignorer    *ignorer
+	hasFile    func(string) bool
+	groups     map[string][]string
+	analyzers  []analyzer
+	pp         *pp.PrettyPrinter
 }
 
 type Option struct {
 	DefaultBranch string
 	MergeBase     string
+	Remote        string
+	Base          string
+	Head          string
+	FindRenames   int
+	FindCopies    int
+	Worktree      bool
+	Staged        bool
+	Untracked     bool
+	GitHubEvent   bool
+	StateFile     string
 	Types         []string
 	Ignores       []string
+	Includes      []string
+	Excludes      []string
+	IgnoreFile    string
 	GroupBy       []string
+	GroupByMarker []string
+	Groups        []string
+	Analyzers     []string
+	GoPackages    bool
 	DirExist      string
+	Sort          string
 }
 
 func New(path string, args []string, opt Option) (client, error) {
-	changes, err := git.Open(git.Config{
+	if len(opt.GroupBy) > 0 && len(opt.GroupByMarker) > 0 {
+		return client{}, errors.New("cannot specify both patterns and marker files to group by")
+	}
+
+	result, err := git.Open(git.Config{
 		Path:          path,
 		DefaultBranch: opt.DefaultBranch,
 		MergeBase:     opt.MergeBase,
+		Remote:        opt.Remote,
+		Base:          opt.Base,
+		Head:          opt.Head,
+		FindRenames:   opt.FindRenames,
+		FindCopies:    opt.FindCopies,
+		Worktree:      opt.Worktree,
+		Staged:        opt.Staged,
+		Untracked:     opt.Untracked,
+		GitHubEvent:   opt.GitHubEvent,
+		StateFile:     opt.StateFile,
 	})
 	if err != nil {
 		return client{}, err
 	}
 
+	fsys := result.FS()
+	ignorer, err := newIgnorer(fsys, path, opt.IgnoreFile)
+	if err != nil {
+		return client{}, err
+	}
+
+	groups, err := parseGroups(opt.Groups)
+	if err != nil {
+		return client{}, err
+	}
+
+	var analyzers []analyzer
+	for _, name := range opt.Analyzers {
+		a, err := newAnalyzer(name, fsys)
+		if err != nil {
+			return client{}, err
+		}
+		analyzers = append(analyzers, a)
+	}
+
 	printer := pp.New()
 	printer.SetColoringEnabled(false)
 	printer.SetExportedOnly(true)
 	return client{
-		args:    args,
-		opt:     opt,
-		changes: changes,
-		pp:      printer,
+		repo:       path,
+		fsys:       fsys,
+		args:       args,
+		opt:        opt,
+		changes:    result.Changes,
+		comparison: getComparison(result),
+		ignorer:    ignorer,
+		hasFile:    result.HasFile,
+		groups:     groups,
+		analyzers:  analyzers,
+		pp:         printer,
 	}, nil
 }
 
 func (c client) Run() (Diff, error) {
-	changes := c.changes
+	all := c.changes
+	if len(c.opt.Types) > 0 {
+		// filter by change type
+		all = lo.Filter[git.Change](all, func(change git.Change, _ int) bool {
+			return lo.Contains(c.opt.Types, change.Type.String())
+		})
+	}
+	changes := all
 
-	for _, arg := range c.args {
-		// filter by given dir names
+	if len(c.args) > 0 {
+		// filter by given dir names or patterns, any of which matches
 		changes = lo.Filter[git.Change](changes, func(change git.Change, _ int) bool {
-			return strings.Index(filepath.Dir(change.Path), arg) == 0
+			return lo.SomeBy(c.args, func(arg string) bool {
+				if change.Type == git.Rename && inDir(change.OldPath, arg) {
+					return true
+				}
+				return inDir(change.Path, arg)
+			})
 		})
 	}
 
@@ -70,25 +150,27 @@ func (c client) Run() (Diff, error) {
 		})
 	}
 
-	if len(c.opt.Types) > 0 {
-		// filter by change type
-		filtered := []git.Change{}
-		for _, ty := range c.opt.Types {
-			filtered = append(filtered, lo.Filter[git.Change](changes, func(change git.Change, _ int) bool {
-				switch ty {
-				case "added":
-					return change.Type == git.Addition
-				case "deleted":
-					return change.Type == git.Deletion
-				case "modified":
-					return change.Type == git.Modification
-				}
-				return false
-			})...)
-		}
-		changes = filtered
+	if len(c.opt.Includes) > 0 {
+		// filter by given patterns of file path
+		includes := parseRules(c.opt.Includes)
+		changes = lo.Filter[git.Change](changes, func(change git.Change, _ int) bool {
+			return matchRules(includes, change.Path)
+		})
 	}
 
+	if len(c.opt.Excludes) > 0 {
+		// filter out by given patterns of file path
+		excludes := parseRules(c.opt.Excludes)
+		changes = lo.Filter[git.Change](changes, func(change git.Change, _ int) bool {
+			return !matchRules(excludes, change.Path)
+		})
+	}
+
+	// filter out by ignore files
+	changes = lo.Filter[git.Change](changes, func(change git.Change, _ int) bool {
+		return !c.ignorer.ignored(change.Path)
+	})
+
 	// filter by the existence of parent dir
 	changes = lo.Filter[git.Change](changes, func(change git.Change, _ int) bool {
 		_, err := os.Stat(filepath.Dir(change.Path))
@@ -103,12 +185,80 @@ func (c client) Run() (Diff, error) {
 		}
 	})
 
+	files := c.getFiles(changes)
+	sortFiles(files, c.opt.Sort)
+
+	var dirs []Dir
+	if len(c.opt.GroupByMarker) > 0 {
+		dirs = c.getDirs(findDirWithMarkers(changes, c.opt.GroupByMarker, c.hasFile), nil)
+	} else {
+		dirs = c.getDirs(findDirWithPatterns(changes, expandAllCaptures(c.opt.GroupBy)), c.opt.GroupBy)
+	}
+	var deps []dependent
+	if c.opt.DirExist != "false" {
+		// dependents are found in the existing files. They are looked for with
+		// the changes out of the given dirs as well, e.g. a changed module
+		// called from the given dir, and filtered by the dirs afterwards.
+		for _, a := range c.analyzers {
+			found, err := a.dependents(all)
+			if err != nil {
+				return Diff{}, err
+			}
+			deps = append(deps, lo.Filter[dependent](found, func(dep dependent, _ int) bool {
+				return c.selectedDir(dep.path)
+			})...)
+		}
+	}
+	dirs = c.addLabels(addDependents(dirs, deps), c.opt.GroupBy)
+	sortDirs(dirs, c.opt.Sort)
+
+	var groups map[string][]Dir
+	if len(c.groups) > 0 {
+		groups = make(map[string][]Dir, len(c.groups))
+		for name, patterns := range c.groups {
+			globs := expandAllCaptures(patterns)
+			dirs := c.getDirs(findDirWithPatterns(changes, globs), patterns)
+			dirs = c.addLabels(addDependents(dirs, groupDependents(deps, globs)), patterns)
+			sortDirs(dirs, c.opt.Sort)
+			if dirs == nil {
+				dirs = []Dir{}
+			}
+			groups[name] = dirs
+		}
+	}
+
+	var affected []Package
+	if c.opt.GoPackages {
+		var err error
+		// packages out of the given dirs may be imported from the dirs
+		affected, err = golang{fsys: c.fsys}.affected(all)
+		if err != nil {
+			return Diff{}, err
+		}
+		affected = lo.Filter[Package](affected, func(pkg Package, _ int) bool {
+			return c.selectedDir(pkg.Dir)
+		})
+	}
+
 	return Diff{
-		Files: c.getFiles(changes),
-		Dirs:  c.getDirs(changes),
+		Files:      files,
+		Dirs:       dirs,
+		Groups:     groups,
+		Affected:   affected,
+		Comparison: c.comparison,
 	}, nil
 }
 
+// SaveState stores the head commit in the state file if it's given. It should
+// be called after the result is used successfully.
+func (c client) SaveState() error {
+	if len(c.opt.StateFile) == 0 {
+		return nil
+	}
+	log.Printf("[INFO] saving head commit %s to state file %s", c.comparison.Head.Hash, c.opt.StateFile)
+	return git.WriteState(c.opt.StateFile, c.comparison.Head.Hash)
+}
+
 func (c client) getFiles(changes []git.Change) []File {
 	var files []File
 
@@ -118,9 +268,11 @@ func (c client) getFiles(changes []git.Change) []File {
 	return files
 }
 
-func (c client) getDirs(changes []git.Change) []Dir {
+// getDirs makes dirs of the grouped changes. The labels of a dir are captured
+// by the patterns if any.
+func (c client) getDirs(found map[string][]git.Change, patterns []string) []Dir {
 	matrix := make(map[string]Dir)
-	for path, changes := range findDirWithPatterns(changes, c.opt.GroupBy) {
+	for path, changes := range found {
 		for _, change := range changes {
 			dir, ok := matrix[path]
 			if ok {
@@ -134,7 +286,8 @@ func (c client) getDirs(changes []git.Change) []Dir {
 						_, err := os.Stat(path)
 						return err == nil
 					}(),
-					Files: []File{getFile(change)},
+					Files:  []File{getFile(change)},
+					Labels: getLabels(patterns, path),
 				}
 			}
 			matrix[path] = dir
@@ -148,6 +301,61 @@ func (c client) getDirs(changes []git.Change) []Dir {
 	return dirs
 }
 
+// selectedDir reports whether the dir is under any of the given dirs and not
+// ignored by the patterns of --ignore.
+func (c client) selectedDir(dir string) bool {
+	if len(c.args) > 0 && !lo.SomeBy(c.args, func(arg string) bool { return underDir(dir, arg) }) {
+		return false
+	}
+	return !lo.SomeBy(c.opt.Ignores, func(ignore string) bool {
+		match, _ := doublestar.Match(ignore, dir)
+		return match
+	})
+}
+
+// addLabels adds the labels captured by the patterns to the dirs which don't
+// have them yet, e.g. dependents, and the labels given by the analyzers.
+func (c client) addLabels(dirs []Dir, patterns []string) []Dir {
+	for i := range dirs {
+		dirs[i].Labels = mergeLabels(dirs[i].Labels, getLabels(patterns, dirs[i].Path))
+	}
+	for _, a := range c.analyzers {
+		if l, ok := a.(labeler); ok {
+			for i := range dirs {
+				dirs[i].Labels = mergeLabels(dirs[i].Labels, l.labels(dirs[i]))
+			}
+		}
+	}
+	return dirs
+}
+
+// inDir reports whether the file is located under the dir. The dir matches
+// whole path components, so "a/b" doesn't contain "a/bc/d". It can also be a
+// doublestar pattern, which matches any ancestor dir of the file.
+func inDir(path, dir string) bool {
+	return underDir(filepath.Dir(path), dir)
+}
+
+// underDir reports whether the parent dir is the dir or located under it.
+func underDir(parent, dir string) bool {
+	dir = filepath.Clean(dir)
+	if dir == "." {
+		return true
+	}
+
+	if hasMeta(dir) {
+		return lo.SomeBy(getSteps(parent), func(step string) bool {
+			matched, _ := doublestar.Match(dir, step)
+			return matched
+		})
+	}
+	return parent == dir || strings.HasPrefix(parent, dir+"/")
+}
+
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[{\\")
+}
+
 func getSteps(path string) []string {
 	var steps []string
 	step := path
@@ -168,34 +376,48 @@ func findDirWithPatterns(changes []git.Change, patterns []string) map[string][]g
 	if len(patterns) == 0 {
 		// if no given patterns, find files located in parent dir.
 		min = false
-		patterns = lo.Uniq[string](lo.Map[git.Change](changes, func(change git.Change, _ int) string {
-			return filepath.Dir(change.Path)
+		patterns = lo.Uniq[string](lo.FlatMap[git.Change](changes, func(change git.Change, _ int) []string {
+			if change.Type == git.Rename {
+				return []string{filepath.Dir(change.Path), filepath.Dir(change.OldPath)}
+			}
+			return []string{filepath.Dir(change.Path)}
 		}))
 	}
 
 	for _, change := range changes {
-		steps := getSteps(filepath.Dir(change.Path))
-		var dirs []string
-		for _, pattern := range patterns {
-			dirs = append(dirs, lo.FilterMap[string, string](steps, func(step string, _ int) (string, bool) {
-				matched, _ := doublestar.Match(pattern, step)
-				return step, matched
-			})...)
+		paths := []string{change.Path}
+		if change.Type == git.Rename {
+			// the old dir is also changed because the file was moved out of it
+			paths = append(paths, change.OldPath)
 		}
-		if len(dirs) == 0 {
-			continue
+		var groups []string
+		for _, path := range paths {
+			steps := getSteps(filepath.Dir(path))
+			var dirs []string
+			for _, pattern := range patterns {
+				dirs = append(dirs, lo.FilterMap[string, string](steps, func(step string, _ int) (string, bool) {
+					matched, _ := doublestar.Match(pattern, step)
+					return step, matched
+				})...)
+			}
+			if len(dirs) == 0 {
+				continue
+			}
+			var dir string
+			if min {
+				dir = lo.MinBy(dirs, func(item string, dir string) bool {
+					return len(strings.Split(item, "/")) < len(strings.Split(dir, "/"))
+				})
+			} else {
+				dir = lo.MaxBy(dirs, func(item string, dir string) bool {
+					return len(strings.Split(item, "/")) > len(strings.Split(dir, "/"))
+				})
+			}
+			groups = append(groups, dir)
 		}
-		var dir string
-		if min {
-			dir = lo.MinBy(dirs, func(item string, dir string) bool {
-				return len(strings.Split(item, "/")) < len(strings.Split(dir, "/"))
-			})
-		} else {
-			dir = lo.MaxBy(dirs, func(item string, dir string) bool {
-				return len(strings.Split(item, "/")) > len(strings.Split(dir, "/"))
-			})
+		for _, dir := range lo.Uniq(groups) {
+			found[dir] = append(found[dir], change)
 		}
-		found[dir] = append(found[dir], change)
 	}
 
 	return found
@@ -1,10 +1,14 @@
 package detect
 
 import (
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/b4b4r07/changed-objects/internal/git"
 	"github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
 )
 
 func Test_findDirWithPatterns(t *testing.T) {
@@ -184,16 +188,819 @@ func Test_findDirWithPatterns(t *testing.T) {
 				"kubernetes/service-b/overlays/prod": {{Path: "kubernetes/service-b/overlays/prod/a.yaml", Type: git.Addition}},
 			},
 		},
+		{
+			name: "terraform: renamed between dirs",
+			changes: []git.Change{
+				{Path: "terraform/service-b/prod/a.tf", OldPath: "terraform/service-a/prod/a.tf", Type: git.Rename, Similarity: 100},
+				{Path: "terraform/service-b/prod/b.tf", Type: git.Addition},
+			},
+			patterns: []string{},
+			want: map[string][]git.Change{
+				"terraform/service-a/prod": {
+					{Path: "terraform/service-b/prod/a.tf", OldPath: "terraform/service-a/prod/a.tf", Type: git.Rename, Similarity: 100},
+				},
+				"terraform/service-b/prod": {
+					{Path: "terraform/service-b/prod/a.tf", OldPath: "terraform/service-a/prod/a.tf", Type: git.Rename, Similarity: 100},
+					{Path: "terraform/service-b/prod/b.tf", Type: git.Addition},
+				},
+			},
+		},
+		{
+			name: "terraform: renamed within a group",
+			changes: []git.Change{
+				{Path: "terraform/service-a/prod/modules/a.tf", OldPath: "terraform/service-a/prod/a.tf", Type: git.Rename, Similarity: 80},
+			},
+			patterns: []string{"terraform/*/{dev,prod}"},
+			want: map[string][]git.Change{
+				"terraform/service-a/prod": {
+					{Path: "terraform/service-a/prod/modules/a.tf", OldPath: "terraform/service-a/prod/a.tf", Type: git.Rename, Similarity: 80},
+				},
+			},
+		},
+		{
+			name: "terraform: single alternative",
+			changes: []git.Change{
+				{Path: "terraform/service-a/prod/a.tf", Type: git.Addition},
+				{Path: "terraform/service-a/dev/a.tf", Type: git.Addition},
+			},
+			patterns: []string{"terraform/*/{prod}"},
+			want: map[string][]git.Change{
+				"terraform/service-a/prod": {{Path: "terraform/service-a/prod/a.tf", Type: git.Addition}},
+			},
+		},
+		{
+			name: "terraform: named segments",
+			changes: []git.Change{
+				{Path: "terraform/service-a/prod/modules/a.tf", Type: git.Addition},
+			},
+			patterns: []string{"terraform/{:service}/{:env}"},
+			want: map[string][]git.Change{
+				"terraform/service-a/prod": {{Path: "terraform/service-a/prod/modules/a.tf", Type: git.Addition}},
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := findDirWithPatterns(tt.changes, expandAllCaptures(tt.patterns))
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_sortFiles(t *testing.T) {
+	files := []File{
+		{Path: "terraform/b/main.tf", Type: git.Modification},
+		{Path: "terraform/a/prod/main.tf", Type: git.Addition},
+		{Path: "README.md", Type: git.Modification},
+		{Path: "terraform/a/main.tf", Type: git.Deletion},
+	}
+
+	cases := []struct {
+		key  string
+		want []string
+	}{
+		{
+			key:  "path",
+			want: []string{"README.md", "terraform/a/main.tf", "terraform/a/prod/main.tf", "terraform/b/main.tf"},
+		},
+		{
+			key:  "type",
+			want: []string{"terraform/a/prod/main.tf", "terraform/a/main.tf", "README.md", "terraform/b/main.tf"},
+		},
+		{
+			key:  "depth",
+			want: []string{"README.md", "terraform/a/main.tf", "terraform/b/main.tf", "terraform/a/prod/main.tf"},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+			got := append([]File{}, files...)
+			sortFiles(got, tt.key)
+			paths := make([]string, len(got))
+			for i, file := range got {
+				paths[i] = file.Path
+			}
+			if diff := cmp.Diff(paths, tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_inDir(t *testing.T) {
+	cases := []struct {
+		path string
+		dir  string
+		want bool
+	}{
+		{path: "terraform/app/main.tf", dir: "terraform/app", want: true},
+		{path: "terraform/app/prod/main.tf", dir: "terraform/app", want: true},
+		{path: "terraform/app/main.tf", dir: "terraform/app/", want: true},
+		{path: "terraform/app-legacy/main.tf", dir: "terraform/app", want: false},
+		{path: "terraform/main.tf", dir: "terraform/app", want: false},
+		{path: "main.tf", dir: ".", want: true},
+		{path: "terraform/app/prod/main.tf", dir: "terraform/*/prod", want: true},
+		{path: "terraform/app/prod/child/main.tf", dir: "terraform/*/prod", want: true},
+		{path: "terraform/app/dev/main.tf", dir: "terraform/*/prod", want: false},
+		{path: "terraform/app/dev/main.tf", dir: "terraform/**/{dev,prod}", want: true},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.path+" in "+tt.dir, func(t *testing.T) {
+			t.Parallel()
+			if got := inDir(tt.path, tt.dir); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchRules(t *testing.T) {
+	cases := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{name: "name at any depth", patterns: []string{"*.md"}, path: "terraform/a/README.md", want: true},
+		{name: "doublestar", patterns: []string{"**/README.md"}, path: "terraform/a/README.md", want: true},
+		{name: "anchored", patterns: []string{"docs/*.md"}, path: "terraform/docs/a.md", want: false},
+		{name: "parent dir", patterns: []string{"docs"}, path: "docs/guide/a.md", want: true},
+		{name: "dir only", patterns: []string{"main.tf/"}, path: "terraform/main.tf", want: false},
+		{name: "dir only matches parent", patterns: []string{"terraform/"}, path: "terraform/main.tf", want: true},
+		{name: "negated", patterns: []string{"*.md", "!CHANGELOG.md"}, path: "terraform/CHANGELOG.md", want: false},
+		{name: "last match wins", patterns: []string{"!CHANGELOG.md", "*.md"}, path: "terraform/CHANGELOG.md", want: true},
+		{name: "no match", patterns: []string{"*.md"}, path: "terraform/main.tf", want: false},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := matchRules(parseRules(tt.patterns), tt.path); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ignorer(t *testing.T) {
+	repo := writeFiles(t, map[string]string{
+		".changed-objects-ignore":              "# docs\n*.md\n",
+		"terraform/.changed-objects-ignore":    "!README.md\n*.tfvars\n",
+		"kubernetes/.changed-objects-ignore":   "/base/\n",
+		"kubernetes/a/.changed-objects-ignore": "",
+	})
+
+	i, err := newIgnorer(os.DirFS(repo), repo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{path: "README.md", want: true},
+		{path: "kubernetes/a/README.md", want: true},
+		{path: "terraform/a/README.md", want: false},
+		{path: "terraform/a/CHANGELOG.md", want: true},
+		{path: "terraform/a/prod.tfvars", want: true},
+		{path: "prod.tfvars", want: false},
+		{path: "kubernetes/base/a.yaml", want: true},
+		{path: "kubernetes/a/base/a.yaml", want: false},
+	}
+
+	for _, tt := range cases {
+		if got := i.ignored(tt.path); got != tt.want {
+			t.Errorf("ignored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func Test_parseGroups(t *testing.T) {
+	cases := []struct {
+		name    string
+		specs   []string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:  "none",
+			specs: nil,
+			want:  nil,
+		},
+		{
+			name:  "named patterns",
+			specs: []string{"terraform=terraform/**/{dev,prod}", "k8s=kubernetes/**/overlays/*", "terraform=modules/*"},
+			want: map[string][]string{
+				"terraform": {"terraform/**/{dev,prod}", "modules/*"},
+				"k8s":       {"kubernetes/**/overlays/*"},
+			},
+		},
+		{
+			name:    "no name",
+			specs:   []string{"terraform/**"},
+			wantErr: true,
+		},
+		{
+			name:    "empty pattern",
+			specs:   []string{"terraform="},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			specs:   []string{"terraform=terraform/[a"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseGroups(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_captureLabels(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		want    map[string]string
+		wantOK  bool
+	}{
+		{
+			pattern: "terraform/{:service}/{:env}",
+			path:    "terraform/service-a/prod",
+			want:    map[string]string{"service": "service-a", "env": "prod"},
+			wantOK:  true,
+		},
+		{
+			pattern: "terraform/**/{:service}/{dev,prod}",
+			path:    "terraform/team/service-a/dev",
+			want:    map[string]string{"service": "service-a"},
+			wantOK:  true,
+		},
+		{
+			pattern: "**/{:env}",
+			path:    "kubernetes/service-b/overlays/prod",
+			want:    map[string]string{"env": "prod"},
+			wantOK:  true,
+		},
+		{
+			pattern: "terraform/*/{prod}",
+			path:    "terraform/service-a/prod",
+			want:    map[string]string{},
+			wantOK:  true,
+		},
+		{
+			pattern: "terraform/*/{prod}",
+			path:    "terraform/service-a/dev",
+			want:    nil,
+			wantOK:  false,
+		},
+		{
+			pattern: "terraform/*/prod",
+			path:    "terraform/service-a/prod",
+			want:    map[string]string{},
+			wantOK:  true,
+		},
+		{
+			pattern: "terraform/{:service}/{:env}",
+			path:    "terraform/service-a",
+			want:    nil,
+			wantOK:  false,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			t.Parallel()
+			got, ok := captureLabels(tt.pattern, tt.path)
+			if ok != tt.wantOK {
+				t.Fatalf("got %v, want %v", ok, tt.wantOK)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_findDirWithMarkers(t *testing.T) {
+	files := map[string]bool{
+		"go.mod":                                  true,
+		"terraform/team/service-a/prod/main.tf":   true,
+		"terraform/service-b/main.tf":             true,
+		"kubernetes/service-c/kustomization.yaml": true,
+	}
+	hasFile := func(path string) bool { return files[path] }
+
+	cases := []struct {
+		name    string
+		changes []git.Change
+		markers []string
+		want    map[string][]git.Change
+	}{
+		{
+			name: "nearest ancestor",
+			changes: []git.Change{
+				{Path: "terraform/team/service-a/prod/main.tf", Type: git.Modification},
+				{Path: "terraform/team/service-a/prod/files/policy.json", Type: git.Addition},
+				{Path: "terraform/service-b/main.tf", Type: git.Deletion},
+				{Path: "terraform/README.md", Type: git.Modification},
+			},
+			markers: []string{"main.tf"},
+			want: map[string][]git.Change{
+				"terraform/team/service-a/prod": {
+					{Path: "terraform/team/service-a/prod/main.tf", Type: git.Modification},
+					{Path: "terraform/team/service-a/prod/files/policy.json", Type: git.Addition},
+				},
+				"terraform/service-b": {
+					{Path: "terraform/service-b/main.tf", Type: git.Deletion},
+				},
+			},
+		},
+		{
+			name: "multiple markers and root",
+			changes: []git.Change{
+				{Path: "kubernetes/service-c/deployment.yaml", Type: git.Modification},
+				{Path: "internal/detect/detect.go", Type: git.Modification},
+			},
+			markers: []string{"main.tf", "kustomization.yaml", "go.mod"},
+			want: map[string][]git.Change{
+				"kubernetes/service-c": {
+					{Path: "kubernetes/service-c/deployment.yaml", Type: git.Modification},
+				},
+				".": {
+					{Path: "internal/detect/detect.go", Type: git.Modification},
+				},
+			},
+		},
+		{
+			name: "rename between projects",
+			changes: []git.Change{
+				{Path: "terraform/service-b/vpc.tf", Type: git.Rename, OldPath: "terraform/team/service-a/prod/vpc.tf", Similarity: 100},
+			},
+			markers: []string{"main.tf"},
+			want: map[string][]git.Change{
+				"terraform/service-b": {
+					{Path: "terraform/service-b/vpc.tf", Type: git.Rename, OldPath: "terraform/team/service-a/prod/vpc.tf", Similarity: 100},
+				},
+				"terraform/team/service-a/prod": {
+					{Path: "terraform/service-b/vpc.tf", Type: git.Rename, OldPath: "terraform/team/service-a/prod/vpc.tf", Similarity: 100},
+				},
+			},
+		},
 	}
 
 	for _, tt := range cases {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got := findDirWithPatterns(tt.changes, tt.patterns)
+			got := findDirWithMarkers(tt.changes, tt.markers, hasFile)
 			if diff := cmp.Diff(got, tt.want); diff != "" {
 				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
 			}
 		})
 	}
 }
+
+func Test_terraform(t *testing.T) {
+	repo := writeFiles(t, map[string]string{
+		"modules/vpc/main.tf":     `resource "aws_vpc" "this" {}`,
+		"modules/network/main.tf": "module \"vpc\" {\n  source = \"../vpc\"\n}\n",
+		"terraform/prod/main.tf":  "module \"network\" {\n  source = \"../../modules/network\"\n}\nmodule \"s3\" {\n  source = \"terraform-aws-modules/s3-bucket/aws\"\n}\n",
+		"terraform/dev/main.tf":   "module \"vpc\" {\n  source = \"../../modules/vpc\"\n  cidr   = var.cidr\n}\n",
+		"terraform/stg/main.tf":   "module \"network\" {\n  source = \"../../modules/network\"\n",
+	})
+
+	cases := []struct {
+		name    string
+		changes []git.Change
+		want    []dependent
+	}{
+		{
+			name: "transitive",
+			changes: []git.Change{
+				{Path: "modules/vpc/main.tf", Type: git.Modification},
+			},
+			want: []dependent{
+				{
+					path:    "terraform/dev",
+					reason:  "calls changed module modules/vpc",
+					changes: []git.Change{{Path: "modules/vpc/main.tf", Type: git.Modification}},
+				},
+				{
+					path:    "terraform/prod",
+					reason:  "calls module modules/network which calls changed module modules/vpc",
+					changes: []git.Change{{Path: "modules/vpc/main.tf", Type: git.Modification}},
+				},
+			},
+		},
+		{
+			name: "not a module",
+			changes: []git.Change{
+				{Path: "terraform/dev/main.tf", Type: git.Modification},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := terraform{fsys: os.DirFS(repo)}.dependents(tt.changes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i].path < got[j].path })
+			if diff := cmp.Diff(got, tt.want, cmp.AllowUnexported(dependent{})); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_addDependents(t *testing.T) {
+	vpc := git.Change{Path: "modules/vpc/main.tf", Type: git.Modification}
+	db := git.Change{Path: "modules/db/main.tf", Type: git.Modification}
+	dirs := []Dir{
+		{Path: "terraform/dev", Files: []File{getFile(vpc)}},
+	}
+	deps := []dependent{
+		{path: "terraform/prod", reason: "calls changed module modules/vpc", changes: []git.Change{vpc}},
+		{path: "terraform/dev", reason: "calls changed module modules/vpc", changes: []git.Change{vpc}},
+		{path: "terraform/prod", reason: "calls changed module modules/db", changes: []git.Change{db, vpc}},
+	}
+
+	got := addDependents(dirs, deps)
+	want := []Dir{
+		{Path: "terraform/dev", Files: []File{getFile(vpc)}},
+		{
+			Path:   "terraform/prod",
+			Files:  []File{getFile(vpc), getFile(db)},
+			Reason: "calls changed module modules/vpc; calls changed module modules/db",
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func Test_kustomize(t *testing.T) {
+	repo := writeFiles(t, map[string]string{
+		"kubernetes/service-b/base/kustomization.yaml":          "resources:\n  - deployment.yaml\n",
+		"kubernetes/service-b/base/deployment.yaml":             "kind: Deployment\n",
+		"kubernetes/components/istio/kustomization.yaml":        "kind: Component\nresources:\n  - ../../common/namespace.yaml\n",
+		"kubernetes/common/namespace.yaml":                      "kind: Namespace\n",
+		"kubernetes/common/replicas.yaml":                       "kind: Deployment\n",
+		"kubernetes/service-b/overlays/dev/kustomization.yaml":  "resources:\n  - ../../base\n  - https://github.com/org/repo/deploy?ref=v1\npatches:\n  - path: ../../../common/replicas.yaml\n  - patch: |-\n      kind: Deployment\n",
+		"kubernetes/service-b/overlays/prod/kustomization.yaml": "bases:\n  - ../../base\ncomponents:\n  - ../../../components/istio\npatchesStrategicMerge:\n  - replicas.yaml\n",
+		"kubernetes/service-b/overlays/prod/replicas.yaml":      "kind: Deployment\n",
+	})
+
+	base := git.Change{Path: "kubernetes/service-b/base/deployment.yaml", Type: git.Modification}
+	namespace := git.Change{Path: "kubernetes/common/namespace.yaml", Type: git.Modification}
+	replicas := git.Change{Path: "kubernetes/common/replicas.yaml", Type: git.Modification}
+
+	cases := []struct {
+		name    string
+		changes []git.Change
+		want    []dependent
+	}{
+		{
+			name:    "base",
+			changes: []git.Change{base},
+			want: []dependent{
+				{path: "kubernetes/service-b/overlays/dev", reason: "uses changed kustomization kubernetes/service-b/base", changes: []git.Change{base}},
+				{path: "kubernetes/service-b/overlays/prod", reason: "uses changed kustomization kubernetes/service-b/base", changes: []git.Change{base}},
+			},
+		},
+		{
+			name:    "file used by component",
+			changes: []git.Change{namespace},
+			want: []dependent{
+				{path: "kubernetes/service-b/overlays/prod", reason: "uses kustomization kubernetes/components/istio which uses changed file kubernetes/common/namespace.yaml", changes: []git.Change{namespace}},
+			},
+		},
+		{
+			name:    "patch",
+			changes: []git.Change{replicas},
+			want: []dependent{
+				{path: "kubernetes/service-b/overlays/dev", reason: "uses changed file kubernetes/common/replicas.yaml", changes: []git.Change{replicas}},
+			},
+		},
+		{
+			name:    "overlay",
+			changes: []git.Change{{Path: "kubernetes/service-b/overlays/prod/replicas.yaml", Type: git.Modification}},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := kustomize{fsys: os.DirFS(repo)}.dependents(tt.changes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i].path < got[j].path })
+			if diff := cmp.Diff(got, tt.want, cmp.AllowUnexported(dependent{})); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_helm(t *testing.T) {
+	repo := writeFiles(t, map[string]string{
+		"charts/common/Chart.yaml":         "name: common\n",
+		"charts/api/Chart.yaml":            "name: api\ndependencies:\n  - name: common\n    repository: file://../common\n",
+		"charts/platform/Chart.yaml":       "name: platform\ndependencies:\n  - name: api\n    repository: file://../api\n  - name: redis\n    repository: https://charts.bitnami.com/bitnami\n",
+		"charts/platform/values.yaml":      "",
+		"charts/platform/values-dev.yaml":  "",
+		"charts/platform/values-prod.yaml": "",
+		"charts/legacy/Chart.yaml":         "name: legacy\n",
+		"charts/legacy/requirements.yaml":  "dependencies:\n  - name: common\n    repository: file://../common\n",
+	})
+	h := helm{fsys: os.DirFS(repo)}
+
+	t.Run("dependents", func(t *testing.T) {
+		t.Parallel()
+		common := git.Change{Path: "charts/common/templates/_helpers.tpl", Type: git.Modification}
+		got, err := h.dependents([]git.Change{common})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i].path < got[j].path })
+		want := []dependent{
+			{path: "charts/api", reason: "depends on changed chart charts/common", changes: []git.Change{common}},
+			{path: "charts/legacy", reason: "depends on changed chart charts/common", changes: []git.Change{common}},
+			{path: "charts/platform", reason: "depends on chart charts/api which depends on changed chart charts/common", changes: []git.Change{common}},
+		}
+		if diff := cmp.Diff(got, want, cmp.AllowUnexported(dependent{})); diff != "" {
+			t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+		}
+	})
+
+	cases := []struct {
+		name string
+		dir  Dir
+		want map[string]string
+	}{
+		{
+			name: "values file",
+			dir:  Dir{Path: "charts/platform", Files: []File{{Name: "values-prod.yaml", Path: "charts/platform/values-prod.yaml"}}},
+			want: map[string]string{"chart": "platform", "environments": "prod"},
+		},
+		{
+			name: "templates",
+			dir: Dir{Path: "charts/platform", Files: []File{
+				{Name: "values-prod.yaml", Path: "charts/platform/values-prod.yaml"},
+				{Name: "values.yaml", Path: "charts/platform/values.yaml"},
+			}},
+			want: map[string]string{"chart": "platform", "environments": "dev,prod"},
+		},
+		{
+			name: "no values files",
+			dir:  Dir{Path: "charts/api", Files: []File{{Name: "_helpers.tpl", Path: "charts/common/templates/_helpers.tpl"}}},
+			want: map[string]string{"chart": "api"},
+		},
+		{
+			name: "not a chart",
+			dir:  Dir{Path: "charts", Files: []File{{Name: "README.md", Path: "charts/README.md"}}},
+			want: nil,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(h.labels(tt.dir), tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_golang(t *testing.T) {
+	repo := writeFiles(t, map[string]string{
+		"go.work":                              "go 1.18\n\nuse (\n\t./services\n\t./lib\n)\n",
+		"lib/go.mod":                           "module example.com/lib\n\ngo 1.18\n",
+		"lib/auth/auth.go":                     "package auth\n\nimport \"strings\"\n",
+		"lib/log/log.go":                       "package log\n",
+		"services/go.mod":                      "module example.com/services\n\ngo 1.18\n",
+		"services/internal/db/db.go":           "package db\n\nimport _ \"example.com/lib/log\"\n",
+		"services/internal/session/session.go": "package session\n\nimport \"example.com/lib/auth\"\n",
+		"services/cmd/api/main.go":             "package main\n\nimport (\n\t\"example.com/services/internal/session\"\n\t\"example.com/services/internal/db\"\n)\n",
+		"services/cmd/batch/main.go":           "package main\n\nimport \"example.com/services/internal/db\"\n",
+		"services/cmd/batch/main_test.go":      "package main_test\n\nimport \"example.com/lib/auth\"\n",
+		"services/testdata/x/x.go":             "package x\n\nimport \"example.com/lib/auth\"\n",
+		"unused/go.mod":                        "module example.com/unused\n",
+		"unused/a.go":                          "package unused\n\nimport \"example.com/lib/auth\"\n",
+	})
+
+	cases := []struct {
+		name    string
+		changes []git.Change
+		want    []Package
+	}{
+		{
+			name:    "transitive",
+			changes: []git.Change{{Path: "lib/auth/auth.go", Type: git.Modification}},
+			want: []Package{
+				{ImportPath: "example.com/lib/auth", Dir: "lib/auth"},
+				{ImportPath: "example.com/services/cmd/api", Dir: "services/cmd/api", Main: true, Reason: "imports package example.com/services/internal/session which imports changed package example.com/lib/auth"},
+				{ImportPath: "example.com/services/cmd/batch", Dir: "services/cmd/batch", Main: true, Reason: "imports changed package example.com/lib/auth"},
+				{ImportPath: "example.com/services/internal/session", Dir: "services/internal/session", Reason: "imports changed package example.com/lib/auth"},
+			},
+		},
+		{
+			name:    "go.mod",
+			changes: []git.Change{{Path: "lib/go.mod", Type: git.Modification}},
+			want: []Package{
+				{ImportPath: "example.com/lib/auth", Dir: "lib/auth"},
+				{ImportPath: "example.com/lib/log", Dir: "lib/log"},
+				{ImportPath: "example.com/services/cmd/api", Dir: "services/cmd/api", Main: true, Reason: "imports package example.com/services/internal/session which imports changed package example.com/lib/auth"},
+				{ImportPath: "example.com/services/cmd/batch", Dir: "services/cmd/batch", Main: true, Reason: "imports changed package example.com/lib/auth"},
+				{ImportPath: "example.com/services/internal/db", Dir: "services/internal/db", Reason: "imports changed package example.com/lib/log"},
+				{ImportPath: "example.com/services/internal/session", Dir: "services/internal/session", Reason: "imports changed package example.com/lib/auth"},
+			},
+		},
+		{
+			name:    "not a package",
+			changes: []git.Change{{Path: "README.md", Type: git.Modification}},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := golang{fsys: os.DirFS(repo)}.affected(tt.changes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+// writeFiles writes the files to a temp dir and returns the dir.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRun_analyze(t *testing.T) {
+	repo := writeFiles(t, map[string]string{
+		"modules/vpc/main.tf":    `resource "aws_vpc" "this" {}`,
+		"terraform/prod/main.tf": "module \"vpc\" {\n  source = \"../../modules/vpc\"\n}\n",
+		"terraform/dev/main.tf":  "module \"vpc\" {\n  source = \"../../modules/vpc\"\n}\n",
+	})
+	ignorer, err := newIgnorer(os.DirFS(repo), repo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		args       []string
+		opt        Option
+		groups     map[string][]string
+		want       []string
+		wantGroups map[string][]string
+	}{
+		{
+			name: "all dirs",
+			want: []string{"modules/vpc", "terraform/dev", "terraform/prod"},
+		},
+		{
+			name: "given dir",
+			args: []string{"terraform"},
+			want: []string{"terraform/dev", "terraform/prod"},
+		},
+		{
+			name: "given dir and ignore",
+			args: []string{"terraform"},
+			opt:  Option{Ignores: []string{"terraform/dev"}},
+			want: []string{"terraform/prod"},
+		},
+		{
+			name:   "groups",
+			groups: map[string][]string{"tf": {"terraform/*"}, "modules": {"modules/*"}, "k8s": {"kubernetes/*"}},
+			want:   []string{"modules/vpc", "terraform/dev", "terraform/prod"},
+			wantGroups: map[string][]string{
+				"tf":      {"terraform/dev", "terraform/prod"},
+				"modules": {"modules/vpc"},
+				"k8s":     {},
+			},
+		},
+		{
+			name:   "groups by parent dirs",
+			groups: map[string][]string{"tf": {"terraform"}},
+			want:   []string{"modules/vpc", "terraform/dev", "terraform/prod"},
+			wantGroups: map[string][]string{
+				"tf": {"terraform"},
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := client{
+				args:      tt.args,
+				opt:       tt.opt,
+				groups:    tt.groups,
+				changes:   []git.Change{{Path: "modules/vpc/main.tf", Type: git.Modification}},
+				ignorer:   ignorer,
+				analyzers: []analyzer{terraform{fsys: os.DirFS(repo)}},
+			}
+			diff, err := c.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := lo.Map(diff.Dirs, func(dir Dir, _ int) string { return dir.Path })
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+			var gotGroups map[string][]string
+			if diff.Groups != nil {
+				gotGroups = lo.MapValues(diff.Groups, func(dirs []Dir, _ string) []string {
+					return lo.Map(dirs, func(dir Dir, _ int) string { return dir.Path })
+				})
+			}
+			if diff := cmp.Diff(gotGroups, tt.wantGroups); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRun_goPackages(t *testing.T) {
+	repo := writeFiles(t, map[string]string{
+		"lib/go.mod":               "module example.com/lib\n\ngo 1.18\n",
+		"lib/auth/auth.go":         "package auth\n",
+		"services/go.mod":          "module example.com/services\n\ngo 1.18\n",
+		"services/cmd/api/main.go": "package main\n\nimport \"example.com/lib/auth\"\n",
+	})
+	ignorer, err := newIgnorer(os.DirFS(repo), repo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := client{
+		fsys:    os.DirFS(repo),
+		args:    []string{"services"},
+		opt:     Option{GoPackages: true},
+		changes: []git.Change{{Path: "lib/auth/auth.go", Type: git.Modification}},
+		ignorer: ignorer,
+	}
+	diff, err := c.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Package{
+		{ImportPath: "example.com/services/cmd/api", Dir: "services/cmd/api", Main: true, Reason: "imports changed package example.com/lib/auth"},
+	}
+	if diff := cmp.Diff(diff.Affected, want); diff != "" {
+		t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+	}
+}
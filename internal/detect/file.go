@@ -8,26 +8,65 @@ import (
 )
 
 type File struct {
-	Name      string    `json:"name"`
-	Path      string    `json:"path"`
-	Type      git.Type  `json:"type"`
-	ParentDir ParentDir `json:"parent_dir"`
+	Name      string    `json:"name" yaml:"name"`
+	Path      string    `json:"path" yaml:"path"`
+	Type      git.Type  `json:"type" yaml:"type"`
+	ParentDir ParentDir `json:"parent_dir" yaml:"parent_dir"`
+
+	OldPath    string `json:"old_path,omitempty" yaml:"old_path,omitempty"`
+	Similarity int    `json:"similarity,omitempty" yaml:"similarity,omitempty"`
 }
 
 type ParentDir struct {
-	Path  string `json:"path"`
-	Exist bool   `json:"exist"`
+	Path  string `json:"path" yaml:"path"`
+	Exist bool   `json:"exist" yaml:"exist"`
 }
 
 type Dir struct {
-	Path  string `json:"path"`
-	Exist bool   `json:"exist"`
-	Files []File `json:"files"`
+	Path  string `json:"path" yaml:"path"`
+	Exist bool   `json:"exist" yaml:"exist"`
+	Files []File `json:"files" yaml:"files"`
+	// Labels has path components captured by named segments of the pattern
+	// such as {:service}
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Reason tells why the dir is affected if it isn't changed itself but
+	// depends on changed files
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Package is a Go package affected by the changes.
+type Package struct {
+	ImportPath string `json:"import_path" yaml:"import_path"`
+	Dir        string `json:"dir" yaml:"dir"`
+	// Main is true for the packages of main binaries
+	Main bool `json:"main" yaml:"main"`
+	// Reason tells why the package is affected if it isn't changed itself
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+type Ref struct {
+	Name   string `json:"name" yaml:"name"`
+	Hash   string `json:"hash,omitempty" yaml:"hash,omitempty"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+type Comparison struct {
+	// Branch is the current branch, which is given only when the base is
+	// guessed from it
+	Branch *Ref `json:"branch,omitempty" yaml:"branch,omitempty"`
+	Base   Ref  `json:"base" yaml:"base"`
+	Head   Ref  `json:"head" yaml:"head"`
 }
 
 type Diff struct {
-	Files []File `json:"files"`
-	Dirs  []Dir  `json:"dirs"`
+	Files []File `json:"files" yaml:"files"`
+	Dirs  []Dir  `json:"dirs" yaml:"dirs"`
+	// Groups has dirs per named grouping, which is given only with groupings
+	Groups map[string][]Dir `json:"groups,omitempty" yaml:"groups,omitempty"`
+	// Affected has Go packages importing changed packages, which is given
+	// only with GoPackages
+	Affected   []Package  `json:"affected,omitempty" yaml:"affected,omitempty"`
+	Comparison Comparison `json:"comparison" yaml:"comparison"`
 }
 
 func getFile(change git.Change) File {
@@ -42,5 +81,27 @@ func getFile(change git.Change) File {
 				return err == nil
 			}(),
 		},
+		OldPath:    change.OldPath,
+		Similarity: change.Similarity,
+	}
+}
+
+func getRef(ref git.Ref) Ref {
+	return Ref{
+		Name:   ref.Name,
+		Hash:   ref.Hash,
+		Reason: ref.Reason,
+	}
+}
+
+func getComparison(result git.Result) Comparison {
+	c := Comparison{
+		Base: getRef(result.Base),
+		Head: getRef(result.Head),
+	}
+	if result.Branch != (git.Ref{}) {
+		branch := getRef(result.Branch)
+		c.Branch = &branch
 	}
+	return c
 }
@@ -0,0 +1,251 @@
+package detect
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/b4b4r07/changed-objects/internal/git"
+	"golang.org/x/mod/modfile"
+)
+
+// golang finds the Go packages which import changed packages directly or
+// transitively. Modules are read from go.work (or go.mod files if it doesn't
+// exist) and imports from source files, without running the go command.
+type golang struct {
+	fsys fs.FS
+}
+
+type goModule struct {
+	path string
+	dir  string
+}
+
+type goPackage struct {
+	Package
+	module  string
+	imports []string
+}
+
+func (g golang) affected(changes []git.Change) ([]Package, error) {
+	modules, err := g.modules()
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make(map[string]*goPackage)
+	for _, module := range modules {
+		if err := g.loadPackages(module, modules, pkgs); err != nil {
+			return nil, err
+		}
+	}
+	byDir := make(map[string]*goPackage, len(pkgs))
+	gr := make(graph)
+	for _, pkg := range pkgs {
+		byDir[pkg.Dir] = pkg
+		for _, imp := range pkg.imports {
+			if _, ok := pkgs[imp]; ok {
+				gr.add(pkg.ImportPath, imp)
+			}
+		}
+	}
+
+	// a change of go.mod or go.sum affects all packages of the module
+	changedModules := make(map[string]bool)
+	changed := make(map[string]bool)
+	for _, change := range changes {
+		for _, p := range []string{change.Path, change.OldPath} {
+			if len(p) == 0 {
+				continue
+			}
+			if name := filepath.Base(p); name == "go.mod" || name == "go.sum" {
+				changedModules[filepath.Dir(p)] = true
+			}
+			if pkg, ok := byDir[filepath.Dir(p)]; ok {
+				changed[pkg.ImportPath] = true
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		for _, module := range modules {
+			if module.path == pkg.module && changedModules[module.dir] {
+				changed[pkg.ImportPath] = true
+			}
+		}
+	}
+
+	roots := make([]string, 0, len(changed))
+	for importPath := range changed {
+		roots = append(roots, importPath)
+	}
+	sort.Strings(roots)
+
+	// the first reason in order of import paths is taken if a package imports
+	// multiple changed packages
+	reasons := make(map[string]string)
+	noun := func(importPath string) string { return "package " + importPath }
+	for _, root := range roots {
+		for importPath, reason := range gr.dependents(root, "imports", noun, false) {
+			if _, ok := reasons[importPath]; !ok && !changed[importPath] {
+				reasons[importPath] = reason
+			}
+		}
+	}
+
+	var affected []Package
+	for importPath, pkg := range pkgs {
+		reason, ok := reasons[importPath]
+		if !ok && !changed[importPath] {
+			continue
+		}
+		p := pkg.Package
+		p.Reason = reason
+		affected = append(affected, p)
+	}
+	sort.Slice(affected, func(i, j int) bool {
+		return affected[i].ImportPath < affected[j].ImportPath
+	})
+	return affected, nil
+}
+
+// modules returns the modules used by go.work in the repository root, or the
+// modules of all go.mod files if it doesn't exist.
+func (g golang) modules() ([]goModule, error) {
+	b, err := fs.ReadFile(g.fsys, "go.work")
+	switch {
+	case err == nil:
+		work, err := modfile.ParseWork("go.work", b, nil)
+		if err != nil {
+			return nil, err
+		}
+		var modules []goModule
+		for _, use := range work.Use {
+			dir, ok := joinRepoPath(".", filepath.FromSlash(use.Path))
+			if !ok {
+				log.Printf("[WARN] skip module %s out of the repository", use.Path)
+				continue
+			}
+			module, err := g.readModule(dir)
+			if err != nil {
+				return nil, err
+			}
+			modules = append(modules, module)
+		}
+		return modules, nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	var modules []goModule
+	err = fs.WalkDir(g.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != "." && skipGoDir(d.Name()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "go.mod" {
+			return nil
+		}
+		module, err := g.readModule(filepath.Dir(filepath.FromSlash(p)))
+		if err != nil {
+			return err
+		}
+		modules = append(modules, module)
+		return nil
+	})
+	return modules, err
+}
+
+func (g golang) readModule(dir string) (goModule, error) {
+	b, err := fs.ReadFile(g.fsys, path.Join(filepath.ToSlash(dir), "go.mod"))
+	if err != nil {
+		return goModule{}, err
+	}
+	modulePath := modfile.ModulePath(b)
+	if len(modulePath) == 0 {
+		return goModule{}, errors.New(filepath.Join(dir, "go.mod") + ": no module path")
+	}
+	return goModule{path: modulePath, dir: dir}, nil
+}
+
+// loadPackages reads the imports of the packages in the module, except the
+// dirs of the other modules. Build constraints are not evaluated, so all
+// files including tests are read.
+func (g golang) loadPackages(module goModule, modules []goModule, pkgs map[string]*goPackage) error {
+	nested := make(map[string]bool)
+	for _, m := range modules {
+		if m.dir != module.dir {
+			nested[m.dir] = true
+		}
+	}
+
+	fset := token.NewFileSet()
+	root := filepath.ToSlash(module.dir)
+	return fs.WalkDir(g.fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := filepath.FromSlash(p)
+		if d.IsDir() {
+			if p != root && (skipGoDir(d.Name()) || nested[rel]) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(p) != ".go" {
+			return nil
+		}
+
+		src, err := fs.ReadFile(g.fsys, p)
+		if err != nil {
+			return err
+		}
+		file, err := parser.ParseFile(fset, rel, src, parser.ImportsOnly)
+		if err != nil {
+			log.Printf("[WARN] cannot parse %s: %v", rel, err)
+			return nil
+		}
+
+		dir := filepath.Dir(rel)
+		sub, err := filepath.Rel(module.dir, dir)
+		if err != nil {
+			return err
+		}
+		importPath := path.Join(module.path, filepath.ToSlash(sub))
+		pkg, ok := pkgs[importPath]
+		if !ok {
+			pkg = &goPackage{
+				Package: Package{ImportPath: importPath, Dir: dir},
+				module:  module.path,
+			}
+			pkgs[importPath] = pkg
+		}
+		if file.Name.Name == "main" && !strings.HasSuffix(p, "_test.go") {
+			pkg.Main = true
+		}
+		for _, spec := range file.Imports {
+			imp, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			pkg.imports = append(pkg.imports, imp)
+		}
+		return nil
+	})
+}
+
+// skipGoDir reports whether the go command ignores the dir.
+func skipGoDir(name string) bool {
+	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
@@ -0,0 +1,29 @@
+package detect
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// parseGroups parses named groupings given as "name=pattern". Patterns with
+// the same name make one grouping as --group-by does.
+func parseGroups(specs []string) (map[string][]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	groups := make(map[string][]string)
+	for _, spec := range specs {
+		name, pattern, ok := strings.Cut(spec, "=")
+		if !ok || len(name) == 0 || len(pattern) == 0 {
+			return nil, fmt.Errorf("%s: group must be given as name=pattern", spec)
+		}
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("%s: invalid pattern in group %s", pattern, name)
+		}
+		groups[name] = append(groups[name], pattern)
+	}
+	return groups, nil
+}
@@ -0,0 +1,155 @@
+package detect
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/b4b4r07/changed-objects/internal/git"
+	"gopkg.in/yaml.v3"
+)
+
+// chartFile is the file which makes a dir a Helm chart.
+const chartFile = "Chart.yaml"
+
+// valuesFileRe matches values files per environment such as values-prod.yaml.
+var valuesFileRe = regexp.MustCompile(`^values-(.+)\.ya?ml$`)
+
+// helm finds the charts which depend on changed local charts through
+// dependencies with file:// repositories, and labels chart dirs with the
+// environments of their values files.
+type helm struct {
+	fsys fs.FS
+}
+
+type chart struct {
+	Name         string            `yaml:"name"`
+	Dependencies []chartDependency `yaml:"dependencies"`
+}
+
+type chartDependency struct {
+	Name       string `yaml:"name"`
+	Repository string `yaml:"repository"`
+}
+
+func (h helm) dependents(changes []git.Change) ([]dependent, error) {
+	g, err := h.graph()
+	if err != nil {
+		return nil, err
+	}
+
+	charts := make([]string, 0, len(g))
+	for chart := range g {
+		charts = append(charts, chart)
+	}
+	sort.Strings(charts)
+
+	noun := func(dir string) string { return "chart " + dir }
+	var deps []dependent
+	for _, chart := range charts {
+		changed := changesInDir(changes, chart)
+		if len(changed) == 0 {
+			continue
+		}
+		for path, reason := range g.dependents(chart, "depends on", noun, false) {
+			deps = append(deps, dependent{path: path, reason: reason, changes: changed})
+		}
+	}
+	return deps, nil
+}
+
+// graph returns the local chart dependencies in the repository, which maps
+// a chart dir to the charts depending on it. The dependencies of Helm 2 in
+// requirements.yaml are also read.
+func (h helm) graph() (graph, error) {
+	g := make(graph)
+	err := walkRepo(h.fsys, func(rel string) error {
+		if filepath.Base(rel) != chartFile {
+			return nil
+		}
+
+		dir := filepath.Dir(rel)
+
+		var deps []chartDependency
+		for _, name := range []string{chartFile, "requirements.yaml"} {
+			c, err := readChart(h.fsys, filepath.Join(dir, name))
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					log.Printf("[WARN] cannot parse %s: %v", filepath.Join(dir, name), err)
+				}
+				continue
+			}
+			deps = append(deps, c.Dependencies...)
+		}
+
+		for _, dep := range deps {
+			if !strings.HasPrefix(dep.Repository, "file://") {
+				continue
+			}
+			target, ok := joinRepoPath(dir, strings.TrimPrefix(dep.Repository, "file://"))
+			if !ok {
+				continue
+			}
+			log.Printf("[TRACE] helm: %s depends on %s", dir, target)
+			g.add(dir, target)
+		}
+		return nil
+	})
+	return g, err
+}
+
+// labels returns the chart name and the environments of the releases which
+// the changes of the chart dir affect. Changes only in values files such as
+// values-prod.yaml affect their environments, otherwise all of them.
+func (h helm) labels(dir Dir) map[string]string {
+	c, err := readChart(h.fsys, filepath.Join(dir.Path, chartFile))
+	if err != nil {
+		return nil
+	}
+
+	envs := make(map[string]bool)
+	for _, file := range dir.Files {
+		m := valuesFileRe.FindStringSubmatch(file.Name)
+		if m == nil || filepath.Dir(file.Path) != dir.Path {
+			envs = nil
+			break
+		}
+		envs[m[1]] = true
+	}
+	if envs == nil {
+		envs = make(map[string]bool)
+		entries, _ := fs.ReadDir(h.fsys, filepath.ToSlash(dir.Path))
+		for _, entry := range entries {
+			if m := valuesFileRe.FindStringSubmatch(entry.Name()); m != nil && !entry.IsDir() {
+				envs[m[1]] = true
+			}
+		}
+	}
+
+	labels := map[string]string{"chart": c.Name}
+	if len(envs) > 0 {
+		names := make([]string, 0, len(envs))
+		for env := range envs {
+			names = append(names, env)
+		}
+		sort.Strings(names)
+		labels["environments"] = strings.Join(names, ",")
+	}
+	return labels
+}
+
+func readChart(fsys fs.FS, path string) (chart, error) {
+	b, err := fs.ReadFile(fsys, filepath.ToSlash(path))
+	if err != nil {
+		return chart{}, err
+	}
+	var c chart
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return chart{}, err
+	}
+	return c, nil
+}
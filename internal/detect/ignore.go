@@ -0,0 +1,114 @@
+package detect
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName is the name of files which list gitignore-style patterns of
+// files to skip. The one in a dir applies to the files under the dir.
+const ignoreFileName = ".changed-objects-ignore"
+
+// ignorer matches files against the ignore files in the repository.
+type ignorer struct {
+	fsys  fs.FS
+	rules map[string][]rule
+}
+
+// newIgnorer returns an ignorer with the rules of the root dir, which are read
+// from the given file or the ignore file in the repository root. The ignore
+// files in the repository are read from fsys, i.e. the files being compared.
+func newIgnorer(fsys fs.FS, repo, file string) (*ignorer, error) {
+	i := &ignorer{
+		fsys:  fsys,
+		rules: make(map[string][]rule),
+	}
+
+	if len(file) == 0 {
+		rules, err := readIgnoreFile(fsys, ignoreFileName)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		i.rules["."] = rules
+		return i, nil
+	}
+
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(repo, file)
+	}
+	rules, err := readIgnoreFile(os.DirFS(filepath.Dir(file)), filepath.Base(file))
+	if err != nil {
+		return nil, err
+	}
+	i.rules["."] = rules
+	return i, nil
+}
+
+// ignored reports whether the file is ignored. The ignore files are applied
+// from the root to the parent dir of the file, and the last matching rule
+// wins as with gitignore.
+func (i *ignorer) ignored(path string) bool {
+	steps := []string{"."}
+	if dir := filepath.Dir(path); dir != "." {
+		dirs := getSteps(dir)
+		for j := len(dirs) - 1; j >= 0; j-- {
+			steps = append(steps, dirs[j])
+		}
+	}
+
+	matched := false
+	for _, dir := range steps {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			continue
+		}
+		for _, r := range i.load(dir) {
+			if r.match(rel) {
+				matched = !r.negate
+			}
+		}
+	}
+	return matched
+}
+
+func (i *ignorer) load(dir string) []rule {
+	rules, ok := i.rules[dir]
+	if ok {
+		return rules
+	}
+	rules, err := readIgnoreFile(i.fsys, path.Join(filepath.ToSlash(dir), ignoreFileName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("[WARN] cannot read ignore file in %s: %v", dir, err)
+	}
+	i.rules[dir] = rules
+	return rules
+}
+
+// readIgnoreFile reads patterns from the file, skipping blank lines and
+// comments starting with "#".
+func readIgnoreFile(fsys fs.FS, name string) ([]rule, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	log.Printf("[DEBUG] reading ignore file: %s", name)
+
+	var rules []rule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, parseRule(line))
+	}
+	return rules, scanner.Err()
+}
@@ -0,0 +1,151 @@
+package detect
+
+import (
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/b4b4r07/changed-objects/internal/git"
+	"gopkg.in/yaml.v3"
+)
+
+// kustomizationFiles are the names of files which make a dir a kustomization.
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// kustomize finds the kustomizations (usually overlays) which use changed
+// bases, components or files through resources, bases, components and
+// patches.
+type kustomize struct {
+	fsys fs.FS
+}
+
+type kustomization struct {
+	Resources             []string         `yaml:"resources"`
+	Bases                 []string         `yaml:"bases"`
+	Components            []string         `yaml:"components"`
+	Patches               []kustomizePatch `yaml:"patches"`
+	PatchesStrategicMerge []string         `yaml:"patchesStrategicMerge"`
+	PatchesJSON6902       []kustomizePatch `yaml:"patchesJson6902"`
+}
+
+type kustomizePatch struct {
+	// Path is empty for inline patches
+	Path string `yaml:"path"`
+}
+
+// UnmarshalYAML accepts a path as well as a mapping with path or patch.
+func (p *kustomizePatch) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		p.Path = node.Value
+		return nil
+	}
+	type plain kustomizePatch
+	return node.Decode((*plain)(p))
+}
+
+func (k kustomization) paths() []string {
+	paths := append([]string{}, k.Resources...)
+	paths = append(paths, k.Bases...)
+	paths = append(paths, k.Components...)
+	paths = append(paths, k.PatchesStrategicMerge...)
+	for _, patches := range [][]kustomizePatch{k.Patches, k.PatchesJSON6902} {
+		for _, patch := range patches {
+			if len(patch.Path) > 0 {
+				paths = append(paths, patch.Path)
+			}
+		}
+	}
+	return paths
+}
+
+func (k kustomize) dependents(changes []git.Change) ([]dependent, error) {
+	g, dirs, err := k.graph()
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]string, 0, len(g))
+	for target := range g {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	noun := func(path string) string {
+		if dirs[path] {
+			return "kustomization " + path
+		}
+		return "file " + path
+	}
+	var deps []dependent
+	for _, target := range targets {
+		changed := changesInDir(changes, target)
+		if len(changed) == 0 {
+			continue
+		}
+		for path, reason := range g.dependents(target, "uses", noun, true) {
+			deps = append(deps, dependent{path: path, reason: reason, changes: changed})
+		}
+	}
+	return deps, nil
+}
+
+// graph returns what the kustomizations in the repository use, which maps a
+// dir or a file to the kustomizations using it. Files in the kustomization
+// dir itself are left out as they are changes of the dir. It also returns the
+// dirs which are kustomizations.
+func (k kustomize) graph() (graph, map[string]bool, error) {
+	g := make(graph)
+	dirs := make(map[string]bool)
+	err := walkRepo(k.fsys, func(rel string) error {
+		if !isKustomizationFile(filepath.Base(rel)) {
+			return nil
+		}
+
+		dir := filepath.Dir(rel)
+		dirs[dir] = true
+
+		b, err := fs.ReadFile(k.fsys, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		var kust kustomization
+		if err := yaml.Unmarshal(b, &kust); err != nil {
+			log.Printf("[WARN] cannot parse %s: %v", rel, err)
+			return nil
+		}
+
+		for _, p := range kust.paths() {
+			if strings.Contains(p, "://") {
+				// remote resources
+				continue
+			}
+			target, ok := joinRepoPath(dir, p)
+			if !ok {
+				continue
+			}
+			fi, err := fs.Stat(k.fsys, filepath.ToSlash(target))
+			if err != nil {
+				// remote resources such as github.com/org/repo or inline patches
+				continue
+			}
+			if !fi.IsDir() && filepath.Dir(target) == dir {
+				continue
+			}
+			log.Printf("[TRACE] kustomize: %s uses %s", dir, target)
+			g.add(dir, target)
+		}
+		return nil
+	})
+	return g, dirs, err
+}
+
+func isKustomizationFile(name string) bool {
+	for _, file := range kustomizationFiles {
+		if name == file {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package detect
+
+import (
+	"path/filepath"
+
+	"github.com/b4b4r07/changed-objects/internal/git"
+	"github.com/samber/lo"
+)
+
+// findDirWithMarkers groups the changes by the nearest ancestor dir (or the
+// parent dir itself) which has any of the marker files such as main.tf or
+// go.mod. Files outside such dirs are left out as with patterns.
+func findDirWithMarkers(changes []git.Change, markers []string, hasFile func(string) bool) map[string][]git.Change {
+	roots := make(map[string]string)
+	root := func(dir string) (string, bool) {
+		if root, ok := roots[dir]; ok {
+			return root, len(root) > 0
+		}
+		steps := getSteps(dir)
+		if dir != "." {
+			steps = append(steps, ".")
+		}
+		var found string
+		for _, step := range steps {
+			if lo.SomeBy(markers, func(marker string) bool {
+				return hasFile(filepath.Join(step, marker))
+			}) {
+				found = step
+				break
+			}
+		}
+		roots[dir] = found
+		return found, len(found) > 0
+	}
+
+	found := make(map[string][]git.Change)
+	for _, change := range changes {
+		paths := []string{change.Path}
+		if change.Type == git.Rename {
+			// the old project is also changed because the file was moved out of it
+			paths = append(paths, change.OldPath)
+		}
+		var groups []string
+		for _, path := range paths {
+			if dir, ok := root(filepath.Dir(path)); ok {
+				groups = append(groups, dir)
+			}
+		}
+		for _, dir := range lo.Uniq(groups) {
+			found[dir] = append(found[dir], change)
+		}
+	}
+	return found
+}
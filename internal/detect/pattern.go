@@ -0,0 +1,80 @@
+package detect
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// rule is a gitignore-style pattern of doublestar.
+type rule struct {
+	pattern string
+	// negate is true if the pattern starts with "!"
+	negate bool
+	// dirOnly is true if the pattern ends with "/"
+	dirOnly bool
+	// anchored is true if the pattern contains "/" other than at the end,
+	// otherwise it matches a name at any depth
+	anchored bool
+}
+
+func parseRule(s string) rule {
+	var r rule
+	if strings.HasPrefix(s, "!") {
+		r.negate = true
+		s = s[1:]
+	}
+	if strings.HasSuffix(s, "/") {
+		r.dirOnly = true
+		s = strings.TrimSuffix(s, "/")
+	}
+	if strings.Contains(s, "/") {
+		r.anchored = true
+		s = strings.TrimPrefix(s, "/")
+	}
+	r.pattern = s
+	return r
+}
+
+func parseRules(patterns []string) []rule {
+	rules := make([]rule, 0, len(patterns))
+	for _, pattern := range patterns {
+		rules = append(rules, parseRule(pattern))
+	}
+	return rules
+}
+
+// match reports whether the rule matches the file or any of its parent dirs.
+func (r rule) match(path string) bool {
+	var targets []string
+	if !r.dirOnly {
+		targets = append(targets, path)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		targets = append(targets, getSteps(dir)...)
+	}
+
+	for _, target := range targets {
+		if !r.anchored {
+			target = filepath.Base(target)
+		}
+		if matched, _ := doublestar.Match(r.pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRules reports whether the file is matched by the rules. Like
+// gitignore, the last matching rule wins, so a negated rule can bring back
+// the files matched by the preceding rules.
+func matchRules(rules []rule, path string) bool {
+	matched := false
+	for _, r := range rules {
+		if r.match(path) {
+			matched = !r.negate
+		}
+	}
+	return matched
+}
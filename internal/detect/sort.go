@@ -0,0 +1,47 @@
+package detect
+
+import (
+	"sort"
+	"strings"
+)
+
+// sortFiles sorts files by the given key: path (default), type or depth. Ties
+// are broken by path so that the order is always the same for the same input.
+func sortFiles(files []File, key string) {
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		switch key {
+		case "type":
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+		case "depth":
+			if depth(a.Path) != depth(b.Path) {
+				return depth(a.Path) < depth(b.Path)
+			}
+		}
+		return a.Path < b.Path
+	})
+}
+
+// sortDirs sorts dirs by the given key and files within each dir as well.
+// Dirs have no type, so they are sorted by path for type.
+func sortDirs(dirs []Dir, key string) {
+	for _, dir := range dirs {
+		sortFiles(dir.Files, key)
+	}
+	sort.SliceStable(dirs, func(i, j int) bool {
+		a, b := dirs[i], dirs[j]
+		if key == "depth" && depth(a.Path) != depth(b.Path) {
+			return depth(a.Path) < depth(b.Path)
+		}
+		return a.Path < b.Path
+	})
+}
+
+func depth(path string) int {
+	if path == "." {
+		return 0
+	}
+	return len(strings.Split(path, "/"))
+}
@@ -0,0 +1,127 @@
+package detect
+
+import (
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/b4b4r07/changed-objects/internal/git"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// terraform finds the root modules which call changed local modules through
+// module blocks such as source = "../../modules/vpc".
+type terraform struct {
+	fsys fs.FS
+}
+
+var moduleSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "module", LabelNames: []string{"name"}}},
+}
+
+var sourceSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "source"}},
+}
+
+func (t terraform) dependents(changes []git.Change) ([]dependent, error) {
+	g, err := t.graph()
+	if err != nil {
+		return nil, err
+	}
+
+	modules := make([]string, 0, len(g))
+	for module := range g {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+
+	var deps []dependent
+	for _, module := range modules {
+		changed := changesInDir(changes, module)
+		if len(changed) == 0 {
+			continue
+		}
+		noun := func(dir string) string { return "module " + dir }
+		for path, reason := range g.dependents(module, "calls", noun, true) {
+			deps = append(deps, dependent{path: path, reason: reason, changes: changed})
+		}
+	}
+	return deps, nil
+}
+
+// graph returns the local module calls in the repository, which maps a
+// module dir to the dirs calling it.
+func (t terraform) graph() (graph, error) {
+	g := make(graph)
+	parser := hclparse.NewParser()
+	err := walkRepo(t.fsys, func(rel string) error {
+		if filepath.Ext(rel) != ".tf" {
+			return nil
+		}
+
+		dir := filepath.Dir(rel)
+		b, err := fs.ReadFile(t.fsys, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		for _, source := range moduleSources(parser, rel, b) {
+			if !strings.HasPrefix(source, "./") && !strings.HasPrefix(source, "../") {
+				// registry or remote modules
+				continue
+			}
+			module, ok := joinRepoPath(dir, source)
+			if !ok {
+				continue
+			}
+			log.Printf("[TRACE] terraform: %s calls %s", dir, module)
+			g.add(dir, module)
+		}
+		return nil
+	}, ".terraform")
+	return g, err
+}
+
+// moduleSources returns the sources of module blocks in the file. Files which
+// can't be parsed are skipped.
+func moduleSources(parser *hclparse.Parser, path string, src []byte) []string {
+	file, diags := parser.ParseHCL(src, path)
+	if diags.HasErrors() {
+		log.Printf("[WARN] cannot parse %s: %v", path, diags)
+		return nil
+	}
+	content, _, _ := file.Body.PartialContent(moduleSchema)
+
+	var sources []string
+	for _, block := range content.Blocks {
+		attrs, _, _ := block.Body.PartialContent(sourceSchema)
+		attr, ok := attrs.Attributes["source"]
+		if !ok {
+			continue
+		}
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || value.Type() != cty.String || !value.IsKnown() || value.IsNull() {
+			continue
+		}
+		sources = append(sources, value.AsString())
+	}
+	return sources
+}
+
+// changesInDir returns the changes of files under the dir (or the file
+// itself), including files moved out of it.
+func changesInDir(changes []git.Change, dir string) []git.Change {
+	in := func(path string) bool {
+		return len(path) > 0 && (path == dir || inDir(path, dir))
+	}
+	var found []git.Change
+	for _, change := range changes {
+		if in(change.Path) || in(change.OldPath) {
+			found = append(found, change)
+		}
+	}
+	return found
+}
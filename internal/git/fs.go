@@ -0,0 +1,145 @@
+package git
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// FS returns the files of the head commit, or the working tree when comparing
+// with it or the index, so that files such as Terraform modules are read from
+// what is compared even if another revision is checked out.
+func (r Result) FS() fs.FS {
+	if len(r.trees.worktree) > 0 {
+		return os.DirFS(r.trees.worktree)
+	}
+	return treeFS{tree: r.trees.head}
+}
+
+// treeFS is a read-only fs.FS of a git tree.
+type treeFS struct {
+	tree *object.Tree
+}
+
+func (t treeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if t.tree == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if name == "." {
+		return &treeDir{info: fileInfo{name: ".", mode: fs.ModeDir | fs.ModePerm}, tree: t.tree}, nil
+	}
+
+	entry, err := t.tree.FindEntry(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	info, err := entryInfo(t.tree, name, entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	switch entry.Mode {
+	case filemode.Dir:
+		tree, err := t.tree.Tree(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &treeDir{info: info, tree: tree, dir: name}, nil
+	case filemode.Submodule:
+		// the files of submodules aren't in the tree
+		return &treeDir{info: info, tree: &object.Tree{}, dir: name}, nil
+	}
+
+	file, err := t.tree.TreeEntryFile(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &treeFile{info: info, Reader: bytes.NewReader([]byte(content))}, nil
+}
+
+// entryInfo returns the file info of the entry at the path in the tree.
+func entryInfo(tree *object.Tree, name string, entry *object.TreeEntry) (fileInfo, error) {
+	mode, err := entry.Mode.ToOSFileMode()
+	if err != nil {
+		return fileInfo{}, err
+	}
+	info := fileInfo{name: path.Base(name), mode: mode}
+	if entry.Mode.IsFile() {
+		file, err := tree.TreeEntryFile(entry)
+		if err != nil {
+			return fileInfo{}, err
+		}
+		info.size = file.Size
+	}
+	return info, nil
+}
+
+type treeFile struct {
+	*bytes.Reader
+	info fileInfo
+}
+
+func (f *treeFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *treeFile) Close() error { return nil }
+
+type treeDir struct {
+	info   fileInfo
+	tree   *object.Tree
+	dir    string
+	offset int
+}
+
+func (d *treeDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *treeDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.dir, Err: fs.ErrInvalid}
+}
+
+func (d *treeDir) Close() error { return nil }
+
+func (d *treeDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.tree.Entries[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	entries := make([]fs.DirEntry, 0, len(rest))
+	for i := range rest {
+		info, err := entryInfo(d.tree, rest[i].Name, &rest[i])
+		if err != nil {
+			return entries, &fs.PathError{Op: "readdir", Path: d.dir, Err: err}
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+		d.offset++
+	}
+	return entries, nil
+}
+
+type fileInfo struct {
+	name string
+	mode fs.FileMode
+	size int64
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) Mode() fs.FileMode  { return i.mode }
+func (i fileInfo) ModTime() time.Time { return time.Time{} }
+func (i fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i fileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,63 @@
+package git
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestResult_FS(t *testing.T) {
+	r := newTestRepo(t)
+	r.write(map[string]string{"modules/vpc/main.tf": "v1", "terraform/prod/main.tf": "prod"})
+	base := r.commit("base")
+	r.write(map[string]string{"modules/vpc/main.tf": "v2"})
+	head := r.commit("head")
+	// another revision is checked out and changed locally
+	r.checkout(base)
+	r.write(map[string]string{"modules/vpc/main.tf": "local", "terraform/dev/main.tf": "dev"})
+
+	cases := []struct {
+		name  string
+		cfg   Config
+		files map[string]string
+	}{
+		{
+			name:  "head commit",
+			cfg:   Config{Base: base.String(), Head: head.String()},
+			files: map[string]string{"modules/vpc/main.tf": "v2", "terraform/prod/main.tf": "prod"},
+		},
+		{
+			name:  "working tree",
+			cfg:   Config{Base: base.String(), Worktree: true},
+			files: map[string]string{"modules/vpc/main.tf": "local", "terraform/dev/main.tf": "dev"},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := tt.cfg
+			cfg.Path = r.dir
+			result, err := Open(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fsys := result.FS()
+			var names []string
+			for name, want := range tt.files {
+				names = append(names, name)
+				b, err := fs.ReadFile(fsys, name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != want {
+					t.Errorf("%s: got %q, want %q", name, b, want)
+				}
+			}
+			if err := fstest.TestFS(fsys, names...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -18,109 +20,379 @@ type Config struct {
 	Path          string
 	DefaultBranch string
 	MergeBase     string
+	Remote        string
+	Base          string
+	Head          string
+
+	// FindRenames is a similarity threshold in percent to detect renames.
+	// Zero disables rename detection.
+	FindRenames int
+	// FindCopies is a similarity threshold in percent to detect copies.
+	// Zero disables copy detection.
+	FindCopies int
+
+	// Worktree compares the base commit with the working tree instead of
+	// the head commit. Staged compares it with the index instead.
+	Worktree  bool
+	Staged    bool
+	Untracked bool
+
+	// GitHubEvent takes the base and head commits from the event which
+	// triggers the GitHub Actions workflow.
+	GitHubEvent bool
+
+	// StateFile takes the base commit from the file storing the head commit
+	// of the last successful run.
+	StateFile string
 }
 
 type Change struct {
 	Path string
 	Type Type
+
+	// OldPath and Similarity are only set for renames and copies
+	OldPath    string
+	Similarity int
+}
+
+// Ref describes a point of the comparison and why it was chosen.
+type Ref struct {
+	Name   string
+	Hash   string
+	Reason string
+}
+
+// Result is the changes between the base and the head with how they were
+// chosen.
+type Result struct {
+	Changes []Change
+	Branch  Ref
+	Base    Ref
+	Head    Ref
+
+	trees trees
+}
+
+// branchEnvs are environment variables which CI services set to the branch
+// name, in order of preference. They are used when HEAD is detached.
+var branchEnvs = []string{
+	"GITHUB_HEAD_REF",
+	"GITHUB_REF_NAME",
+	"CI_MERGE_REQUEST_SOURCE_BRANCH_NAME",
+	"CI_COMMIT_REF_NAME",
+	"BUILDKITE_BRANCH",
+	"CIRCLE_BRANCH",
 }
 
-func Open(cfg Config) ([]Change, error) {
+func Open(cfg Config) (Result, error) {
+	for _, threshold := range []int{cfg.FindRenames, cfg.FindCopies} {
+		if threshold < 0 || threshold > 100 {
+			return Result{}, fmt.Errorf("similarity threshold must be between 0 and 100: %d", threshold)
+		}
+	}
+
+	if len(cfg.MergeBase) > 0 && (len(cfg.Base) > 0 || cfg.GitHubEvent || len(cfg.StateFile) > 0) {
+		return Result{}, errors.New("cannot specify merge-base with base revision, GitHub event or state file")
+	}
+
+	var event string
+	if cfg.GitHubEvent {
+		if len(cfg.Base) > 0 || len(cfg.Head) > 0 {
+			return Result{}, errors.New("cannot specify base or head revision when reading GitHub event")
+		}
+		if cfg.Staged || cfg.Worktree || cfg.Untracked {
+			return Result{}, errors.New("cannot compare with the index or the working tree when reading GitHub event")
+		}
+		event = os.Getenv("GITHUB_EVENT_NAME")
+		base, head, err := readGitHubEvent(event, os.Getenv("GITHUB_EVENT_PATH"))
+		if err != nil {
+			return Result{}, err
+		}
+		log.Printf("[INFO] %s event: base %q, head %q", event, base, head)
+		cfg.Base, cfg.Head = base, head
+	}
+
+	var state string
+	if len(cfg.StateFile) > 0 {
+		if len(cfg.Base) > 0 || cfg.GitHubEvent {
+			return Result{}, errors.New("cannot specify base revision when reading state file")
+		}
+		if cfg.Staged || cfg.Worktree || cfg.Untracked {
+			return Result{}, errors.New("cannot compare with the index or the working tree when reading state file")
+		}
+		var err error
+		state, err = readState(cfg.StateFile)
+		if err != nil {
+			return Result{}, err
+		}
+		log.Printf("[INFO] state file %s: %q", cfg.StateFile, state)
+		cfg.Base = state
+	}
+
+	if cfg.Untracked {
+		// untracked files exist only in the working tree
+		cfg.Worktree = true
+	}
+	if cfg.Staged && cfg.Worktree {
+		return Result{}, errors.New("cannot compare with both the index and the working tree")
+	}
+	if (cfg.Staged || cfg.Worktree) && len(cfg.Head) > 0 {
+		return Result{}, errors.New("cannot specify head revision when comparing with the index or the working tree")
+	}
+
 	repo, err := git.PlainOpen(cfg.Path)
 	if err != nil {
-		return []Change{}, fmt.Errorf("cannot open repository: %w", err)
+		return Result{}, fmt.Errorf("cannot open repository: %w", err)
 	}
 	cfg.repo = repo
 
-	currentBranch, err := cfg.getCurrentBranch()
+	var result Result
+
+	log.Printf("[DEBUG] Getting head commit")
+	head, err := cfg.headCommit(&result.Head)
 	if err != nil {
-		return []Change{}, err
+		return Result{}, withFetchHint(err, cfg.GitHubEvent)
+	}
+
+	base, err := cfg.baseCommit(head, &result.Branch, &result.Base)
+	if err != nil {
+		return Result{}, withFetchHint(err, cfg.GitHubEvent && len(cfg.Base) > 0)
+	}
+
+	if len(cfg.StateFile) > 0 {
+		if len(state) > 0 {
+			ok, err := base.IsAncestor(head)
+			if err != nil {
+				return Result{}, err
+			}
+			if !ok {
+				return Result{}, fmt.Errorf("last processed commit %s in state file %s is not an ancestor of head %s: the history may have been rewritten by force-push",
+					state, cfg.StateFile, head.Hash)
+			}
+			result.Base.Reason = fmt.Sprintf("last processed commit in state file %s", cfg.StateFile)
+		} else {
+			result.Base.Reason = fmt.Sprintf("state file %s doesn't exist yet, so %s", cfg.StateFile, result.Base.Reason)
+		}
+	}
+
+	if cfg.GitHubEvent {
+		result.Head.Reason = fmt.Sprintf("head of %s event", event)
+		switch {
+		case len(cfg.Base) > 0 && (event == "pull_request" || event == "pull_request_target"):
+			// the base of the event is the tip of the base branch, so compare
+			// from the fork point as "Files changed" of the pull request does
+			mbs, err := base.MergeBase(head)
+			if err != nil {
+				return Result{}, withFetchHint(err, true)
+			}
+			if len(mbs) == 0 {
+				return Result{}, fmt.Errorf("base %s and head %s of %s event have no merge-base: fetch the history, e.g. with fetch-depth: 0 of actions/checkout", cfg.Base, cfg.Head, event)
+			}
+			base = mbs[0]
+			result.Base.Reason = fmt.Sprintf("merge-base of base and head of %s event", event)
+		case len(cfg.Base) > 0:
+			result.Base.Reason = fmt.Sprintf("base of %s event", event)
+		default:
+			result.Base.Reason = fmt.Sprintf("%s event has no base, so %s", event, result.Base.Reason)
+		}
+	}
+
+	if len(cfg.MergeBase) > 0 {
+		log.Printf("[DEBUG] Comparing with merge-base")
+		mb, err := cfg.mergeBaseCommit(cfg.MergeBase, head.Hash.String())
+		if err != nil {
+			return Result{}, err
+		}
+		if mb != nil {
+			base = mb
+			// the current branch isn't used to choose the base
+			result.Branch = Ref{}
+			result.Base = Ref{
+				Name:   cfg.MergeBase,
+				Reason: fmt.Sprintf("merge-base of %s and head", cfg.MergeBase),
+			}
+		}
+	}
+	result.Base.Hash = base.Hash.String()
+	log.Printf("[INFO] base: %s (%s): %s", result.Base.Name, result.Base.Hash, result.Base.Reason)
+	log.Printf("[INFO] head: %s (%s): %s", result.Head.Name, result.Head.Hash, result.Head.Reason)
+
+	result.trees, err = cfg.getTrees(base, head)
+	if err != nil {
+		return Result{}, err
+	}
+
+	switch {
+	case cfg.Staged:
+		result.Head.Reason += " with staged changes"
+		result.Changes, err = cfg.getWorktreeChanges(base, head)
+	case cfg.Worktree:
+		result.Head.Reason += " with working tree changes"
+		result.Changes, err = cfg.getWorktreeChanges(base, head)
+	default:
+		result.Changes, err = cfg.getChanges(base, head)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result, nil
+}
+
+// withFetchHint tells how to fix the error if the commits in the GitHub event
+// are not fetched, which is likely to happen with a shallow clone.
+func withFetchHint(err error, event bool) error {
+	if event && (errors.Is(err, plumbing.ErrReferenceNotFound) || errors.Is(err, plumbing.ErrObjectNotFound)) {
+		return fmt.Errorf("%w: fetch the commits in GitHub event, e.g. with fetch-depth: 0 of actions/checkout", err)
+	}
+	return err
+}
+
+func (c Config) headName() string {
+	if len(c.Head) == 0 {
+		return "HEAD"
+	}
+	return c.Head
+}
+
+// headCommit returns the commit given by Head, or the HEAD commit if it's empty.
+func (c Config) headCommit(ref *Ref) (*object.Commit, error) {
+	var commit *object.Commit
+	var err error
+	if len(c.Head) == 0 {
+		*ref = Ref{Name: c.headName(), Reason: "current HEAD"}
+		commit, err = c.currentCommit()
+	} else {
+		*ref = Ref{Name: c.headName(), Reason: "specified as head"}
+		commit, err = c.revisionCommit(c.Head)
 	}
-	log.Printf("[TRACE] Getting current branch: %s", currentBranch)
+	if err != nil {
+		return nil, err
+	}
+	ref.Hash = commit.Hash.String()
+	return commit, nil
+}
+
+// baseCommit returns the commit given by Base. If it's empty, the base is
+// guessed from the current branch: the previous commit on the default branch,
+// otherwise the remote default branch.
+func (c Config) baseCommit(head *object.Commit, branch, ref *Ref) (*object.Commit, error) {
+	if len(c.Base) > 0 {
+		log.Printf("[DEBUG] Getting base commit from %q", c.Base)
+		*ref = Ref{Name: c.Base, Reason: "specified as base"}
+		return c.revisionCommit(c.Base)
+	}
+
+	currentBranch, reason, err := c.getCurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	*branch = Ref{Name: currentBranch, Reason: reason}
+	log.Printf("[INFO] current branch: %q: %s", currentBranch, reason)
+
+	remote, err := c.remoteName(currentBranch)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[TRACE] Getting remote name: %s", remote)
 
 	var base *object.Commit
 
 	switch currentBranch {
-	case cfg.DefaultBranch:
+	case c.DefaultBranch:
 		log.Printf("[DEBUG] Getting previous HEAD commit")
-		prev, err := cfg.previousCommit()
+		prev, err := c.previousCommit(head)
 		if err != nil {
-			return []Change{}, err
+			return nil, err
 		}
 		base = prev
+		*ref = Ref{
+			Name:   c.headName() + "^",
+			Reason: fmt.Sprintf("previous commit because current branch is default branch %s", c.DefaultBranch),
+		}
 	default:
 		log.Printf("[DEBUG] Getting remote commit")
-		remote, err := cfg.remoteCommit("origin/" + cfg.DefaultBranch)
+		name := remote + "/" + c.DefaultBranch
+		commit, err := c.remoteCommit(name)
 		if err != nil {
-			return []Change{}, err
+			return nil, err
+		}
+		base = commit
+		*ref = Ref{
+			Name:   name,
+			Reason: fmt.Sprintf("remote default branch because current branch %q is not default branch %s", currentBranch, c.DefaultBranch),
 		}
-		base = remote
 	}
 
 	if base == nil {
-		defaultBranch, err := cfg.getDefaultBranch()
+		defaultBranch, err := c.getDefaultBranch(remote)
 		if err != nil {
-			return []Change{}, fmt.Errorf("%w: default branch %s is not wrong", err, cfg.DefaultBranch)
+			return nil, fmt.Errorf("%w: default branch %s is not wrong", err, c.DefaultBranch)
 		}
 		log.Printf("[DEBUG] base is nil. So get remote commit from %q", defaultBranch)
-		remote, err := cfg.remoteCommit(defaultBranch)
-		if err != nil {
-			return []Change{}, err
-		}
-		base = remote
-	}
-
-	if len(cfg.MergeBase) > 0 {
-		log.Printf("[DEBUG] Comparing with merge-base")
-		h, err := cfg.repo.Head()
+		commit, err := c.remoteCommit(defaultBranch)
 		if err != nil {
-			return []Change{}, err
-		}
-		currentBranch := h.Name().Short()
-		mb, err := cfg.mergeBaseCommit(cfg.MergeBase, currentBranch)
-		if err != nil {
-			return []Change{}, err
+			return nil, err
 		}
-		if mb != nil {
-			base = mb
+		base = commit
+		*ref = Ref{
+			Name:   defaultBranch,
+			Reason: fmt.Sprintf("remote HEAD because %s is not found", ref.Name),
 		}
 	}
 
-	log.Printf("[DEBUG] Getting current commit")
-	current, err := cfg.currentCommit()
+	return base, nil
+}
+
+// getCurrentBranch returns the current branch name with the reason why it's
+// chosen. The branch which HEAD refers to comes first. If HEAD is detached,
+// e.g. on CI, it looks for CI environment variables and then the branch
+// pointing at the HEAD commit if it's the only one.
+func (c Config) getCurrentBranch() (string, string, error) {
+	headRef, err := c.repo.Reference(plumbing.HEAD, false)
 	if err != nil {
-		return []Change{}, err
+		return "", "", err
 	}
 
-	return cfg.getChanges(base, current)
-}
+	if headRef.Type() == plumbing.SymbolicReference && headRef.Target().IsBranch() {
+		return headRef.Target().Short(), "HEAD refers to the branch", nil
+	}
 
-// https://github.com/src-d/go-git/issues/1030
-func (c Config) getCurrentBranch() (string, error) {
-	branchRefs, err := c.repo.Branches()
+	for _, env := range branchEnvs {
+		if name := os.Getenv(env); len(name) > 0 {
+			return name, fmt.Sprintf("HEAD is detached, taken from $%s", env), nil
+		}
+	}
+
+	head, err := c.repo.Head()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	headRef, err := c.repo.Head()
+	branchRefs, err := c.repo.Branches()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	var currentBranchName string
+	var names []string
 	err = branchRefs.ForEach(func(branchRef *plumbing.Reference) error {
-		if branchRef.Hash() == headRef.Hash() {
-			currentBranchName = branchRef.Name().Short()
-			return nil
+		if branchRef.Hash() == head.Hash() {
+			names = append(names, branchRef.Name().Short())
 		}
-
 		return nil
 	})
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return currentBranchName, nil
+	switch len(names) {
+	case 0:
+		return "", "HEAD is detached and no branch points at it", nil
+	case 1:
+		return names[0], "HEAD is detached, the only branch pointing at it", nil
+	default:
+		return "", fmt.Sprintf("HEAD is detached and several branches point at it: %s", strings.Join(names, ", ")), nil
+	}
 }
 
 func (c Config) currentCommit() (*object.Commit, error) {
@@ -133,15 +405,24 @@ func (c Config) currentCommit() (*object.Commit, error) {
 	return c.repo.CommitObject(ref.Hash())
 }
 
-func (c Config) previousCommit() (*object.Commit, error) {
-	hash, err := c.repo.ResolveRevision("HEAD^")
+func (c Config) revisionCommit(rev string) (*object.Commit, error) {
+	hash, err := c.repo.ResolveRevision(plumbing.Revision(rev))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %s", err, rev)
 	}
 
+	log.Printf("[DEBUG] %s: get commit %s", rev, hash)
 	return c.repo.CommitObject(*hash)
 }
 
+func (c Config) previousCommit(head *object.Commit) (*object.Commit, error) {
+	if head.NumParents() == 0 {
+		return nil, fmt.Errorf("%s has no parent commit", head.Hash)
+	}
+
+	return head.Parent(0)
+}
+
 func (c Config) remoteCommit(name string) (*object.Commit, error) {
 	refs, err := c.repo.References()
 	if err != nil {
@@ -209,6 +490,8 @@ const (
 	Addition Type = iota
 	Deletion
 	Modification
+	Rename
+	Copy
 	Unknown
 )
 
@@ -220,6 +503,10 @@ func (t Type) String() string {
 		return "deleted"
 	case Modification:
 		return "modified"
+	case Rename:
+		return "renamed"
+	case Copy:
+		return "copied"
 	default:
 		return "unknown"
 	}
@@ -229,6 +516,10 @@ func (t Type) MarshalJSON() ([]byte, error) {
 	return json.Marshal(t.String())
 }
 
+func (t Type) MarshalYAML() (interface{}, error) {
+	return t.String(), nil
+}
+
 func (c Config) getChanges(from, to *object.Commit) ([]Change, error) {
 	log.Printf("[TRACE] git.getChanges: from %#v, to %#v\n", from, to)
 
@@ -249,7 +540,7 @@ func (c Config) getChanges(from, to *object.Commit) ([]Change, error) {
 
 	log.Printf("[DEBUG] a number of changes: %d", len(changes))
 
-	var cs []Change
+	var entries []entry
 	for _, change := range changes {
 		action, err := change.Action()
 		if err != nil {
@@ -270,17 +561,52 @@ func (c Config) getChanges(from, to *object.Commit) ([]Change, error) {
 		default:
 			ty = Unknown
 		}
-		cs = append(cs, Change{
+		e := entry{change: Change{
 			Path: path,
 			Type: ty,
-		})
+		}}
+		if c.FindRenames > 0 || c.FindCopies > 0 {
+			e.from, e.to, err = change.Files()
+			if err != nil {
+				return []Change{}, err
+			}
+		}
+		entries = append(entries, e)
 	}
 
+	if c.FindRenames > 0 || c.FindCopies > 0 {
+		return c.findRenames(entries, dst)
+	}
+
+	var cs []Change
+	for _, e := range entries {
+		cs = append(cs, e.change)
+	}
 	return cs, nil
 }
 
-func (c Config) getDefaultBranch() (string, error) {
-	name := "refs/remotes/origin/HEAD"
+// remoteName returns Remote if given. Otherwise it returns the remote which the
+// given branch tracks in the repository config, falling back to "origin".
+func (c Config) remoteName(branch string) (string, error) {
+	if len(c.Remote) > 0 {
+		return c.Remote, nil
+	}
+
+	cfg, err := c.repo.Config()
+	if err != nil {
+		return "", err
+	}
+
+	// "." means the branch tracks another local branch
+	if b, ok := cfg.Branches[branch]; ok && len(b.Remote) > 0 && b.Remote != "." {
+		return b.Remote, nil
+	}
+
+	return "origin", nil
+}
+
+func (c Config) getDefaultBranch(remote string) (string, error) {
+	name := fmt.Sprintf("refs/remotes/%s/HEAD", remote)
 	ref, err := c.repo.Reference(plumbing.ReferenceName(name), true)
 	if err != nil {
 		return "", fmt.Errorf("%w: %s", err, name)
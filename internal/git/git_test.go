@@ -0,0 +1,89 @@
+package git
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpen_conflicts(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{
+			name: "merge-base with base",
+			cfg:  Config{Base: "HEAD~3", MergeBase: "HEAD~1"},
+			want: "cannot specify merge-base",
+		},
+		{
+			name: "merge-base with GitHub event",
+			cfg:  Config{GitHubEvent: true, MergeBase: "main"},
+			want: "cannot specify merge-base",
+		},
+		{
+			name: "merge-base with state file",
+			cfg:  Config{StateFile: "state", MergeBase: "main"},
+			want: "cannot specify merge-base",
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Open(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen_branch(t *testing.T) {
+	r := newTestRepo(t)
+	r.write(map[string]string{"a/main.tf": "a"})
+	first := r.commit("first")
+	r.write(map[string]string{"a/main.tf": "b"})
+	r.commit("second")
+
+	cases := []struct {
+		name string
+		cfg  Config
+		want Ref
+	}{
+		{
+			name: "guessed from current branch",
+			cfg:  Config{DefaultBranch: "master"},
+			want: Ref{Name: "master", Reason: "HEAD refers to the branch"},
+		},
+		{
+			name: "base",
+			cfg:  Config{DefaultBranch: "master", Base: first.String()},
+			want: Ref{},
+		},
+		{
+			name: "merge-base",
+			cfg:  Config{DefaultBranch: "master", MergeBase: first.String()},
+			want: Ref{},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := tt.cfg
+			cfg.Path = r.dir
+			result, err := Open(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(result.Branch, tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
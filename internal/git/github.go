@@ -0,0 +1,80 @@
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// githubEvent is a part of the webhook payload which GitHub Actions stores in
+// $GITHUB_EVENT_PATH.
+// https://docs.github.com/en/webhooks/webhook-events-and-payloads
+type githubEvent struct {
+	// push
+	Before string `json:"before"`
+	After  string `json:"after"`
+
+	PullRequest *struct {
+		Base struct {
+			SHA string `json:"sha"`
+		} `json:"base"`
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+
+	MergeGroup *struct {
+		BaseSHA string `json:"base_sha"`
+		HeadSHA string `json:"head_sha"`
+	} `json:"merge_group"`
+}
+
+// readGitHubEvent returns the base and head commit hashes of the event. The
+// base is empty if the event has no base, e.g. a push creating a new branch.
+func readGitHubEvent(name, path string) (string, string, error) {
+	if len(name) == 0 || len(path) == 0 {
+		return "", "", fmt.Errorf("GITHUB_EVENT_NAME and GITHUB_EVENT_PATH are required to read GitHub event")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot open GitHub event: %w", err)
+	}
+	defer f.Close()
+
+	var event githubEvent
+	if err := json.NewDecoder(f).Decode(&event); err != nil {
+		return "", "", fmt.Errorf("cannot parse GitHub event: %w", err)
+	}
+
+	var base, head string
+	switch name {
+	case "push":
+		base, head = event.Before, event.After
+	case "pull_request", "pull_request_target":
+		if event.PullRequest == nil {
+			return "", "", fmt.Errorf("%s event has no pull_request", name)
+		}
+		base, head = event.PullRequest.Base.SHA, event.PullRequest.Head.SHA
+	case "merge_group":
+		if event.MergeGroup == nil {
+			return "", "", fmt.Errorf("%s event has no merge_group", name)
+		}
+		base, head = event.MergeGroup.BaseSHA, event.MergeGroup.HeadSHA
+	default:
+		return "", "", fmt.Errorf("%s event is not supported", name)
+	}
+
+	if len(head) == 0 || head == plumbing.ZeroHash.String() {
+		// e.g. a push deleting a branch
+		return "", "", fmt.Errorf("%s event has no head commit", name)
+	}
+	if base == plumbing.ZeroHash.String() {
+		// a push creating a new branch has no before commit
+		base = ""
+	}
+
+	return base, head, nil
+}
@@ -0,0 +1,113 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_readGitHubEvent(t *testing.T) {
+	cases := []struct {
+		name     string
+		event    string
+		payload  string
+		wantBase string
+		wantHead string
+		wantErr  bool
+	}{
+		{
+			name:     "push",
+			event:    "push",
+			payload:  `{"before":"1111111111111111111111111111111111111111","after":"2222222222222222222222222222222222222222"}`,
+			wantBase: "1111111111111111111111111111111111111111",
+			wantHead: "2222222222222222222222222222222222222222",
+		},
+		{
+			name:     "push: new branch",
+			event:    "push",
+			payload:  `{"before":"0000000000000000000000000000000000000000","after":"2222222222222222222222222222222222222222"}`,
+			wantBase: "",
+			wantHead: "2222222222222222222222222222222222222222",
+		},
+		{
+			name:    "push: deleted branch",
+			event:   "push",
+			payload: `{"before":"1111111111111111111111111111111111111111","after":"0000000000000000000000000000000000000000"}`,
+			wantErr: true,
+		},
+		{
+			name:     "pull_request",
+			event:    "pull_request",
+			payload:  `{"pull_request":{"base":{"sha":"1111111111111111111111111111111111111111"},"head":{"sha":"2222222222222222222222222222222222222222"}}}`,
+			wantBase: "1111111111111111111111111111111111111111",
+			wantHead: "2222222222222222222222222222222222222222",
+		},
+		{
+			name:     "merge_group",
+			event:    "merge_group",
+			payload:  `{"merge_group":{"base_sha":"1111111111111111111111111111111111111111","head_sha":"2222222222222222222222222222222222222222"}}`,
+			wantBase: "1111111111111111111111111111111111111111",
+			wantHead: "2222222222222222222222222222222222222222",
+		},
+		{
+			name:    "unsupported event",
+			event:   "issues",
+			payload: `{}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "event.json")
+			if err := os.WriteFile(path, []byte(tt.payload), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			base, head, err := readGitHubEvent(tt.event, path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if base != tt.wantBase || head != tt.wantHead {
+				t.Errorf("got %q..%q, want %q..%q", base, head, tt.wantBase, tt.wantHead)
+			}
+		})
+	}
+}
+
+func TestOpen_pullRequestEvent(t *testing.T) {
+	r := newTestRepo(t)
+	r.write(map[string]string{"terraform/a/main.tf": "a", "terraform/b/main.tf": "b"})
+	fork := r.commit("fork point")
+
+	// the pull request changes terraform/a
+	r.write(map[string]string{"terraform/a/main.tf": "a2"})
+	head := r.commit("pull request")
+
+	// the base branch moves ahead with a change of terraform/b
+	r.checkout(fork)
+	r.write(map[string]string{"terraform/b/main.tf": "b2"})
+	base := r.commit("base branch")
+
+	path := filepath.Join(t.TempDir(), "event.json")
+	payload := `{"pull_request":{"base":{"sha":"` + base.String() + `"},"head":{"sha":"` + head.String() + `"}}}`
+	if err := os.WriteFile(path, []byte(payload), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_EVENT_NAME", "pull_request")
+	t.Setenv("GITHUB_EVENT_PATH", path)
+
+	result, err := Open(Config{Path: r.dir, GitHubEvent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Base.Hash != fork.String() {
+		t.Errorf("got base %s, want fork point %s", result.Base.Hash, fork)
+	}
+	if diff := cmp.Diff(paths(result.Changes), map[string]Type{"terraform/a/main.tf": Modification}); diff != "" {
+		t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,124 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testRepo is a repository for tests whose commits are made with go-git.
+type testRepo struct {
+	t    *testing.T
+	dir  string
+	repo *git.Repository
+}
+
+func newTestRepo(t *testing.T) testRepo {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testRepo{t: t, dir: dir, repo: repo}
+}
+
+// write writes the files in the working tree.
+func (r testRepo) write(files map[string]string) {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+}
+
+// remove removes the files from the working tree.
+func (r testRepo) remove(names ...string) {
+	r.t.Helper()
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(r.dir, name)); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+}
+
+// stage adds the files to the index, or removes them from it if they are
+// removed from the working tree.
+func (r testRepo) stage(names ...string) {
+	r.t.Helper()
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	for _, name := range names {
+		if _, err := os.Lstat(filepath.Join(r.dir, name)); os.IsNotExist(err) {
+			_, err = wt.Remove(name)
+		} else {
+			_, err = wt.Add(name)
+		}
+		if err != nil {
+			r.t.Fatal(err)
+		}
+	}
+}
+
+// commit commits all changes in the working tree.
+func (r testRepo) commit(msg string) plumbing.Hash {
+	r.t.Helper()
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if err := wt.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		r.t.Fatal(err)
+	}
+	hash, err := wt.Commit(msg, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return hash
+}
+
+// checkout detaches HEAD at the commit.
+func (r testRepo) checkout(hash plumbing.Hash) {
+	r.t.Helper()
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Hash: hash, Force: true}); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// checkoutBranch checks out the branch.
+func (r testRepo) checkoutBranch(name string) {
+	r.t.Helper()
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(name), Force: true}); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func paths(changes []Change) map[string]Type {
+	m := make(map[string]Type, len(changes))
+	for _, change := range changes {
+		m[change.Path] = change.Type
+	}
+	return m
+}
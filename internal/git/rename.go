@@ -0,0 +1,203 @@
+package git
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// entry is a change with the files on both sides, which are needed to compare
+// contents when detecting renames and copies.
+type entry struct {
+	change   Change
+	from, to *object.File
+}
+
+type pair struct {
+	src, dst int
+	score    int
+}
+
+// findRenames pairs added files with deleted files (renames) or with files
+// which still exist (copies) when their contents are similar enough.
+func (c Config) findRenames(entries []entry, base *object.Tree) ([]Change, error) {
+	renameThreshold := c.FindRenames
+	if renameThreshold == 0 {
+		// detecting copies implies detecting renames like git does
+		renameThreshold = c.FindCopies
+	}
+
+	var added, deleted []int
+	for i, e := range entries {
+		switch e.change.Type {
+		case Addition:
+			added = append(added, i)
+		case Deletion:
+			deleted = append(deleted, i)
+		}
+	}
+
+	var pairs []pair
+	for _, dst := range added {
+		for _, src := range deleted {
+			score, err := similarity(entries[src].from, entries[dst].to, renameThreshold)
+			if err != nil {
+				return []Change{}, err
+			}
+			if score >= renameThreshold {
+				pairs = append(pairs, pair{src: src, dst: dst, score: score})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].score > pairs[j].score
+	})
+
+	// a deleted file is renamed to at most one added file
+	renamed := make(map[int]bool)
+	paired := make(map[int]bool)
+	for _, p := range pairs {
+		if renamed[p.src] || paired[p.dst] {
+			continue
+		}
+		renamed[p.src] = true
+		paired[p.dst] = true
+		entries[p.dst].change.Type = Rename
+		entries[p.dst].change.OldPath = entries[p.src].change.Path
+		entries[p.dst].change.Similarity = p.score
+		log.Printf("[DEBUG] detected rename: %s -> %s (%d%%)",
+			entries[p.src].change.Path, entries[p.dst].change.Path, p.score)
+	}
+
+	if c.FindCopies > 0 {
+		err := c.findCopies(entries, added, paired, base)
+		if err != nil {
+			return []Change{}, err
+		}
+	}
+
+	var cs []Change
+	for i, e := range entries {
+		if renamed[i] {
+			continue
+		}
+		cs = append(cs, e.change)
+	}
+	return cs, nil
+}
+
+// findCopies finds the sources of added files which are not paired yet. As
+// comparing with every file in the base tree is expensive, the sources are
+// limited to modified files and files with exactly the same contents.
+func (c Config) findCopies(entries []entry, added []int, paired map[int]bool, base *object.Tree) error {
+	var modified []int
+	for i, e := range entries {
+		if e.change.Type == Modification {
+			modified = append(modified, i)
+		}
+	}
+
+	identical := make(map[plumbing.Hash]string)
+	err := base.Files().ForEach(func(f *object.File) error {
+		if _, ok := identical[f.Hash]; !ok {
+			identical[f.Hash] = f.Name
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, dst := range added {
+		if paired[dst] {
+			continue
+		}
+		e := &entries[dst]
+		if name, ok := identical[e.to.Hash]; ok {
+			e.change.Type = Copy
+			e.change.OldPath = name
+			e.change.Similarity = 100
+			log.Printf("[DEBUG] detected copy: %s -> %s (100%%)", name, e.change.Path)
+			continue
+		}
+		var best pair
+		for _, src := range modified {
+			score, err := similarity(entries[src].from, e.to, c.FindCopies)
+			if err != nil {
+				return err
+			}
+			if score >= c.FindCopies && score > best.score {
+				best = pair{src: src, dst: dst, score: score}
+			}
+		}
+		if best.score == 0 {
+			continue
+		}
+		e.change.Type = Copy
+		e.change.OldPath = entries[best.src].change.Path
+		e.change.Similarity = best.score
+		log.Printf("[DEBUG] detected copy: %s -> %s (%d%%)", e.change.OldPath, e.change.Path, best.score)
+	}
+
+	return nil
+}
+
+// similarity returns how similar the contents of two files are in percent.
+// It returns zero without reading the contents if the sizes are too different
+// to reach the threshold.
+func similarity(src, dst *object.File, threshold int) (int, error) {
+	if src == nil || dst == nil {
+		return 0, nil
+	}
+	if src.Hash == dst.Hash {
+		return 100, nil
+	}
+
+	small, large := src.Size, dst.Size
+	if small > large {
+		small, large = large, small
+	}
+	if small*100 < int64(threshold)*large {
+		return 0, nil
+	}
+
+	a, err := src.Contents()
+	if err != nil {
+		return 0, err
+	}
+	b, err := dst.Contents()
+	if err != nil {
+		return 0, err
+	}
+	return similarityScore(a, b), nil
+}
+
+// similarityScore counts the bytes of lines which both contents have in common
+// and returns the ratio to the larger content, as git's rename detection does.
+func similarityScore(a, b string) int {
+	max := len(a)
+	if len(b) > max {
+		max = len(b)
+	}
+	if max == 0 {
+		return 100
+	}
+
+	lines := make(map[string]int)
+	for _, line := range strings.SplitAfter(a, "\n") {
+		lines[line]++
+	}
+
+	var common int
+	for _, line := range strings.SplitAfter(b, "\n") {
+		if lines[line] > 0 {
+			lines[line]--
+			common += len(line)
+		}
+	}
+
+	return common * 100 / max
+}
@@ -0,0 +1,52 @@
+package git
+
+import "testing"
+
+func Test_similarityScore(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{
+			name: "identical",
+			a:    "a\nb\nc\n",
+			b:    "a\nb\nc\n",
+			want: 100,
+		},
+		{
+			name: "empty",
+			a:    "",
+			b:    "",
+			want: 100,
+		},
+		{
+			name: "nothing in common",
+			a:    "a\nb\n",
+			b:    "c\nd\n",
+			want: 0,
+		},
+		{
+			name: "one line appended",
+			a:    "a\nb\nc\n",
+			b:    "a\nb\nc\nd\n",
+			want: 75,
+		},
+		{
+			name: "duplicated lines are counted once",
+			a:    "a\n",
+			b:    "a\na\n",
+			want: 50,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := similarityScore(tt.a, tt.b); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readState returns the commit hash stored in the state file. It returns an
+// empty string if the file doesn't exist yet, i.e. on the first run.
+func readState(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("cannot read state file: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// WriteState stores the head commit hash in the state file so that the next
+// run starts from it. It should be called only after the run succeeds.
+func WriteState(path, hash string) error {
+	if len(hash) == 0 {
+		return errors.New("no head commit to write to state file")
+	}
+
+	// write to a temp file and rename it not to leave a broken state file
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot write state file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := fmt.Fprintln(f, hash); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write state file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot write state file: %w", err)
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,156 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpen_stateFile(t *testing.T) {
+	r := newTestRepo(t)
+	r.write(map[string]string{"a/main.tf": "1"})
+	first := r.commit("first")
+
+	// a commit which isn't an ancestor of head, e.g. one before force-push
+	r.checkout(first)
+	r.write(map[string]string{"b/main.tf": "2"})
+	rewritten := r.commit("rewritten")
+
+	r.checkoutBranch("master")
+	r.write(map[string]string{"c/main.tf": "3"})
+	r.commit("head")
+
+	cases := []struct {
+		name     string
+		state    string // no state file if empty
+		wantBase string
+		wantErr  string
+	}{
+		{
+			name:     "first run",
+			state:    "",
+			wantBase: first.String(),
+		},
+		{
+			name:     "ancestor",
+			state:    first.String(),
+			wantBase: first.String(),
+		},
+		{
+			name:    "not an ancestor",
+			state:   rewritten.String(),
+			wantErr: "is not an ancestor of head",
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "state")
+			if len(tt.state) > 0 {
+				if err := os.WriteFile(path, []byte(tt.state+"\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result, err := Open(Config{Path: r.dir, DefaultBranch: "master", StateFile: path})
+			switch {
+			case len(tt.wantErr) > 0:
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got error %v, want %q", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case result.Base.Hash != tt.wantBase:
+				t.Errorf("got base %s, want %s", result.Base.Hash, tt.wantBase)
+			}
+
+			// the state file is written only by WriteState after a run succeeds
+			b, err := os.ReadFile(path)
+			if len(tt.state) == 0 {
+				if !os.IsNotExist(err) {
+					t.Errorf("state file is written: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(string(b)); got != tt.state {
+				t.Errorf("got state %s, want %s", got, tt.state)
+			}
+		})
+	}
+}
+
+func TestWriteState(t *testing.T) {
+	const (
+		old  = "1111111111111111111111111111111111111111"
+		hash = "2222222222222222222222222222222222222222"
+	)
+
+	cases := []struct {
+		name    string
+		hash    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "written",
+			hash: hash,
+			want: hash + "\n",
+		},
+		{
+			name:    "no head commit",
+			hash:    "",
+			want:    old + "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			path := filepath.Join(dir, "state")
+			if err := os.WriteFile(path, []byte(old+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := WriteState(path, tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got %q, want %q", b, tt.want)
+			}
+
+			// no temp file is left
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("got %d files in the dir, want only the state file", len(entries))
+			}
+		})
+	}
+
+	t.Run("missing dir", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "missing", "state")
+		if err := WriteState(path, hash); err == nil {
+			t.Fatal("got no error")
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("state file is written: %v", err)
+		}
+	})
+}
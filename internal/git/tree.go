@@ -0,0 +1,51 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// trees are the trees of the base and the head commits, and the working tree
+// when comparing with it or the index.
+type trees struct {
+	base     *object.Tree
+	head     *object.Tree
+	worktree string
+}
+
+func (c Config) getTrees(base, head *object.Commit) (trees, error) {
+	baseTree, err := base.Tree()
+	if err != nil {
+		return trees{}, err
+	}
+	headTree, err := head.Tree()
+	if err != nil {
+		return trees{}, err
+	}
+	t := trees{base: baseTree, head: headTree}
+	if c.Staged || c.Worktree {
+		t.worktree = c.Path
+	}
+	return t, nil
+}
+
+// HasFile reports whether the file exists in the base or the head tree, so
+// that files which were deleted or are being added are both found.
+func (r Result) HasFile(path string) bool {
+	for _, tree := range []*object.Tree{r.trees.base, r.trees.head} {
+		if tree == nil {
+			continue
+		}
+		if _, err := tree.FindEntry(filepath.ToSlash(path)); err == nil {
+			return true
+		}
+	}
+	if len(r.trees.worktree) > 0 {
+		if _, err := os.Stat(filepath.Join(r.trees.worktree, path)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// getWorktreeChanges returns the changes from the base commit to the index
+// (if Staged) or the working tree. The committed changes up to the head
+// commit are taken as they are unless the files are changed again locally.
+func (c Config) getWorktreeChanges(base, head *object.Commit) ([]Change, error) {
+	changes, err := c.getChanges(base, head)
+	if err != nil {
+		return []Change{}, err
+	}
+
+	wt, err := c.repo.Worktree()
+	if err != nil {
+		return []Change{}, err
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return []Change{}, err
+	}
+	log.Printf("[DEBUG] a number of local changes: %d", len(status))
+
+	idx, err := c.repo.Storer.Index()
+	if err != nil {
+		return []Change{}, err
+	}
+
+	tree, err := base.Tree()
+	if err != nil {
+		return []Change{}, err
+	}
+
+	paths := make([]string, 0, len(status))
+	for path := range status {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	overridden := make(map[string]bool)
+	var local []Change
+	for _, path := range paths {
+		st := status[path]
+
+		var hash plumbing.Hash
+		var exist bool
+		if c.Staged {
+			if st.Staging == git.Unmodified || st.Staging == git.Untracked {
+				continue
+			}
+			hash, exist, err = indexHash(idx, path)
+		} else {
+			if st.Worktree == git.Untracked && !c.Untracked {
+				continue
+			}
+			if st.Staging == git.Unmodified && st.Worktree == git.Unmodified {
+				continue
+			}
+			hash, exist, err = worktreeHash(wt, path)
+		}
+		if err != nil {
+			return []Change{}, err
+		}
+		overridden[path] = true
+
+		file, err := tree.File(path)
+		if err != nil && !errors.Is(err, object.ErrFileNotFound) {
+			return []Change{}, err
+		}
+
+		var ty Type
+		switch {
+		case file == nil && exist:
+			ty = Addition
+		case file != nil && !exist:
+			ty = Deletion
+		case file != nil && exist && file.Hash != hash:
+			ty = Modification
+		default:
+			// same as the base commit
+			continue
+		}
+		log.Printf("[TRACE] local change: %s (%s)", path, ty)
+		local = append(local, Change{
+			Path: path,
+			Type: ty,
+		})
+	}
+
+	var cs []Change
+	for _, change := range changes {
+		if !overridden[change.Path] {
+			cs = append(cs, change)
+			continue
+		}
+		if change.Type == Rename && !overridden[change.OldPath] {
+			// the old path is still gone even if the new one is changed again
+			cs = append(cs, Change{
+				Path: change.OldPath,
+				Type: Deletion,
+			})
+		}
+	}
+
+	return append(cs, local...), nil
+}
+
+func indexHash(idx *index.Index, path string) (plumbing.Hash, bool, error) {
+	entry, err := idx.Entry(path)
+	if err != nil {
+		if errors.Is(err, index.ErrEntryNotFound) {
+			return plumbing.ZeroHash, false, nil
+		}
+		return plumbing.ZeroHash, false, err
+	}
+	return entry.Hash, true, nil
+}
+
+func worktreeHash(wt *git.Worktree, path string) (plumbing.Hash, bool, error) {
+	fi, err := wt.Filesystem.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return plumbing.ZeroHash, false, nil
+		}
+		return plumbing.ZeroHash, false, err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := wt.Filesystem.Readlink(path)
+		if err != nil {
+			return plumbing.ZeroHash, false, err
+		}
+		return plumbing.ComputeHash(plumbing.BlobObject, []byte(target)), true, nil
+	}
+
+	f, err := wt.Filesystem.Open(path)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	return plumbing.ComputeHash(plumbing.BlobObject, content), true, nil
+}
@@ -0,0 +1,118 @@
+package git
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpen_worktree(t *testing.T) {
+	// long enough content to be detected as a rename after a small edit
+	content := strings.Repeat("resource \"null_resource\" \"this\" {}\n", 10)
+
+	cases := []struct {
+		name  string
+		setup func(r testRepo) plumbing.Hash
+		cfg   Config
+		want  map[string]Type
+	}{
+		{
+			name: "staged deletion",
+			setup: func(r testRepo) plumbing.Hash {
+				r.write(map[string]string{"a/main.tf": "a", "b/main.tf": "b"})
+				base := r.commit("base")
+				r.remove("a/main.tf")
+				r.stage("a/main.tf")
+				// not staged
+				r.write(map[string]string{"b/main.tf": "b2"})
+				return base
+			},
+			cfg:  Config{Staged: true},
+			want: map[string]Type{"a/main.tf": Deletion},
+		},
+		{
+			name: "untracked addition without --untracked",
+			setup: func(r testRepo) plumbing.Hash {
+				r.write(map[string]string{"a/main.tf": "a"})
+				base := r.commit("base")
+				r.write(map[string]string{"a/main.tf": "a2", "b/main.tf": "b"})
+				return base
+			},
+			cfg:  Config{Worktree: true},
+			want: map[string]Type{"a/main.tf": Modification},
+		},
+		{
+			name: "untracked addition with --untracked",
+			setup: func(r testRepo) plumbing.Hash {
+				r.write(map[string]string{"a/main.tf": "a"})
+				base := r.commit("base")
+				r.write(map[string]string{"a/main.tf": "a2", "b/main.tf": "b"})
+				return base
+			},
+			cfg:  Config{Untracked: true},
+			want: map[string]Type{"a/main.tf": Modification, "b/main.tf": Addition},
+		},
+		{
+			name: "local edit reverting committed change",
+			setup: func(r testRepo) plumbing.Hash {
+				r.write(map[string]string{"a/main.tf": "a", "b/main.tf": "b"})
+				base := r.commit("base")
+				r.write(map[string]string{"a/main.tf": "a2", "b/main.tf": "b2"})
+				r.commit("head")
+				r.write(map[string]string{"a/main.tf": "a"})
+				return base
+			},
+			cfg:  Config{Worktree: true},
+			want: map[string]Type{"b/main.tf": Modification},
+		},
+		{
+			name: "committed rename edited locally",
+			setup: func(r testRepo) plumbing.Hash {
+				r.write(map[string]string{"old/main.tf": content})
+				base := r.commit("base")
+				r.remove("old/main.tf")
+				r.write(map[string]string{"new/main.tf": content})
+				r.commit("head")
+				r.write(map[string]string{"new/main.tf": content + "# edited\n"})
+				return base
+			},
+			cfg:  Config{Worktree: true, FindRenames: 50},
+			want: map[string]Type{"old/main.tf": Deletion, "new/main.tf": Addition},
+		},
+		{
+			name: "committed rename",
+			setup: func(r testRepo) plumbing.Hash {
+				r.write(map[string]string{"old/main.tf": content})
+				base := r.commit("base")
+				r.remove("old/main.tf")
+				r.write(map[string]string{"new/main.tf": content})
+				r.commit("head")
+				return base
+			},
+			cfg:  Config{Worktree: true, FindRenames: 50},
+			want: map[string]Type{"new/main.tf": Rename},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := newTestRepo(t)
+			base := tt.setup(r)
+
+			cfg := tt.cfg
+			cfg.Path = r.dir
+			cfg.Base = base.String()
+			result, err := Open(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(paths(result.Changes), tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/b4b4r07/changed-objects/internal/detect"
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultDestroyWorkflow is the workflow for dirs which no longer exist.
+const defaultDestroyWorkflow = "destroy"
+
+// atlantisConfig is the config to generate Atlantis projects with. Settings
+// are given per pattern, which is usually the same as --group-by.
+type atlantisConfig struct {
+	Projects []atlantisSetting `yaml:"projects"`
+}
+
+type atlantisSetting struct {
+	Pattern           string   `yaml:"pattern"`
+	Workflow          string   `yaml:"workflow"`
+	DestroyWorkflow   string   `yaml:"destroy_workflow"`
+	Workspace         string   `yaml:"workspace"`
+	WhenModified      []string `yaml:"when_modified"`
+	ApplyRequirements []string `yaml:"apply_requirements"`
+}
+
+// https://www.runatlantis.io/docs/repo-level-atlantis-yaml.html
+type atlantisYAML struct {
+	Version  int               `yaml:"version"`
+	Projects []atlantisProject `yaml:"projects"`
+}
+
+type atlantisProject struct {
+	Name              string            `yaml:"name"`
+	Dir               string            `yaml:"dir"`
+	Workspace         string            `yaml:"workspace,omitempty"`
+	Workflow          string            `yaml:"workflow,omitempty"`
+	Autoplan          *atlantisAutoplan `yaml:"autoplan,omitempty"`
+	ApplyRequirements []string          `yaml:"apply_requirements,omitempty"`
+}
+
+type atlantisAutoplan struct {
+	WhenModified []string `yaml:"when_modified"`
+	Enabled      bool     `yaml:"enabled"`
+}
+
+// writeAtlantis writes atlantis.yaml which has a project per dir. The settings
+// of a project come from the first pattern matching the dir. Dirs which no
+// longer exist use the destroy workflow.
+func writeAtlantis(w io.Writer, diff detect.Diff, config string) error {
+	var cfg atlantisConfig
+	if err := yaml.Unmarshal([]byte(config), &cfg); err != nil {
+		return fmt.Errorf("cannot parse Atlantis config: %w", err)
+	}
+	globs := make([]string, len(cfg.Projects))
+	for i, setting := range cfg.Projects {
+		// patterns can have named segments such as {:service} as --group-by
+		globs[i] = detect.ExpandCaptures(setting.Pattern)
+		if !doublestar.ValidatePattern(globs[i]) {
+			return fmt.Errorf("%s: invalid pattern in Atlantis config", setting.Pattern)
+		}
+	}
+
+	out := atlantisYAML{
+		Version:  3,
+		Projects: []atlantisProject{},
+	}
+	for _, dir := range diff.Dirs {
+		var setting atlantisSetting
+		for i, s := range cfg.Projects {
+			if ok, _ := doublestar.Match(globs[i], dir.Path); ok {
+				setting = s
+				break
+			}
+		}
+
+		project := atlantisProject{
+			Name:              dir.Path,
+			Dir:               dir.Path,
+			Workspace:         setting.Workspace,
+			Workflow:          setting.Workflow,
+			ApplyRequirements: setting.ApplyRequirements,
+		}
+		if len(setting.WhenModified) > 0 {
+			project.Autoplan = &atlantisAutoplan{
+				WhenModified: setting.WhenModified,
+				Enabled:      true,
+			}
+		}
+		if !dir.Exist {
+			project.Workflow = setting.DestroyWorkflow
+			if len(project.Workflow) == 0 {
+				project.Workflow = defaultDestroyWorkflow
+			}
+		}
+		out.Projects = append(out.Projects, project)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,42 @@
+package output
+
+import (
+	"io"
+
+	"github.com/b4b4r07/changed-objects/internal/detect"
+	"gopkg.in/yaml.v3"
+)
+
+// writeBuildkite writes a Buildkite pipeline for `buildkite-agent pipeline
+// upload` which has a step per dir. Each step is built from the job template
+// with DIR and EXIST environment variables, labeled with the dir if the
+// template has no label.
+// https://buildkite.com/docs/pipelines/defining-steps#dynamic-pipelines
+func writeBuildkite(w io.Writer, diff detect.Diff, jobTemplate string) error {
+	job, err := parseJobTemplate(jobTemplate)
+	if err != nil {
+		return err
+	}
+
+	steps := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, dir := range diff.Dirs {
+		step := withVars(job, "env", dirVars(dir))
+		if !hasKey(step, "label") {
+			step.Content = append([]*yaml.Node{stringNode("label"), stringNode(dir.Path)}, step.Content...)
+		}
+		steps.Content = append(steps.Content, step)
+	}
+
+	if len(diff.Dirs) == 0 {
+		noop := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			stringNode("label"), stringNode("No changes"),
+			stringNode("command"), stringNode("echo No changes"),
+		}}
+		steps.Content = append(steps.Content, noop)
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+		stringNode("steps"), steps,
+	}}
+	return encodeYAML(w, doc)
+}
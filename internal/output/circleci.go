@@ -0,0 +1,91 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/b4b4r07/changed-objects/internal/detect"
+	"gopkg.in/yaml.v3"
+)
+
+var invalidJobName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// writeCircleCI writes a CircleCI config to continue the pipeline with from a
+// setup workflow, which has a job per dir in a workflow. Each job is built
+// from the job template with DIR and EXIST environment variables.
+// https://circleci.com/docs/dynamic-config/
+func writeCircleCI(w io.Writer, diff detect.Diff, jobTemplate string) error {
+	job, err := parseJobTemplate(jobTemplate)
+	if err != nil {
+		return err
+	}
+
+	jobs := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	workflowJobs := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	names := make(map[string]bool)
+	for _, dir := range diff.Dirs {
+		name := circleCIJobName(dir.Path, names)
+		jobs.Content = append(jobs.Content, stringNode(name), withVars(job, "environment", dirVars(dir)))
+		workflowJobs.Content = append(workflowJobs.Content, stringNode(name))
+	}
+
+	if len(diff.Dirs) == 0 {
+		// a workflow needs at least one job
+		noop := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			stringNode("docker"), {Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{
+				{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{stringNode("image"), stringNode("cimg/base:stable")}},
+			}},
+			stringNode("steps"), {Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{
+				{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{stringNode("run"), stringNode("echo No changes")}},
+			}},
+		}}
+		jobs.Content = append(jobs.Content, stringNode("no-changes"), noop)
+		workflowJobs.Content = append(workflowJobs.Content, stringNode("no-changes"))
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+		stringNode("version"), {Kind: yaml.ScalarNode, Tag: "!!float", Value: "2.1"},
+		stringNode("jobs"), jobs,
+		stringNode("workflows"), {Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			stringNode("changed-objects"), {Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+				stringNode("jobs"), workflowJobs,
+			}},
+		}},
+	}}
+	return encodeYAML(w, doc)
+}
+
+// writeCircleCIParameters writes pipeline parameters to continue the pipeline
+// with. As parameters can't be lists, dirs is a JSON string, and has_changes is
+// true only if it isn't empty.
+func writeCircleCIParameters(w io.Writer, diff detect.Diff) error {
+	dirs := []string{}
+	for _, dir := range diff.Dirs {
+		dirs = append(dirs, dir.Path)
+	}
+	b, err := json.Marshal(dirs)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"has_changes": len(diff.Dirs) > 0,
+		"dirs":        string(b),
+	})
+}
+
+// circleCIJobName returns a unique job name for the dir as CircleCI allows only
+// alphanumerics, "-" and "_" in job names.
+func circleCIJobName(path string, used map[string]bool) string {
+	base := invalidJobName.ReplaceAllString(path, "-")
+	if path == "." {
+		base = "root"
+	}
+	name := base
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	used[name] = true
+	return name
+}
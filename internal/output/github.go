@@ -0,0 +1,125 @@
+package output
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/b4b4r07/changed-objects/internal/detect"
+)
+
+type matrix struct {
+	Include []matrixEntry `json:"include"`
+}
+
+type matrixEntry struct {
+	Dir    string            `json:"dir"`
+	Exist  bool              `json:"exist"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// emptyMatrix is used when no dirs are changed because GitHub Actions rejects
+// a matrix with empty include. Jobs using it should be skipped by has_changes,
+// which is true only if any dir is changed.
+var emptyMatrix = matrix{Include: []matrixEntry{{Dir: "", Exist: false}}}
+
+// groupOutput is the matrix of a named grouping.
+type groupOutput struct {
+	HasChanges bool   `json:"has_changes"`
+	Matrix     matrix `json:"matrix"`
+}
+
+// WriteGitHubOutput appends matrix, has_changes, files and dirs (and groups
+// with named groupings) to the file of step outputs of GitHub Actions, i.e.
+// $GITHUB_OUTPUT.
+// https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions#setting-an-output-parameter
+func WriteGitHubOutput(path string, diff detect.Diff) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot open GitHub output: %w", err)
+	}
+	defer f.Close()
+
+	if err := writeGitHubOutput(f, diff); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func writeGitHubOutput(w io.Writer, diff detect.Diff) error {
+	files := []string{}
+	for _, file := range diff.Files {
+		files = append(files, file.Path)
+	}
+	dirs := []string{}
+	for _, dir := range diff.Dirs {
+		dirs = append(dirs, dir.Path)
+	}
+
+	outputs := []struct {
+		name  string
+		value interface{}
+	}{
+		{"matrix", dirsMatrix(diff.Dirs)},
+		{"has_changes", len(diff.Dirs) > 0},
+		{"files", files},
+		{"dirs", dirs},
+	}
+	if diff.Groups != nil {
+		groups := make(map[string]groupOutput, len(diff.Groups))
+		for name, dirs := range diff.Groups {
+			groups[name] = groupOutput{
+				HasChanges: len(dirs) > 0,
+				Matrix:     dirsMatrix(dirs),
+			}
+		}
+		outputs = append(outputs, struct {
+			name  string
+			value interface{}
+		}{"groups", groups})
+	}
+	for _, output := range outputs {
+		var value string
+		switch v := output.value.(type) {
+		case bool:
+			value = strconv.FormatBool(v)
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			value = string(b)
+		}
+		if err := writeGitHubOutputValue(w, output.name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dirsMatrix(dirs []detect.Dir) matrix {
+	if len(dirs) == 0 {
+		return emptyMatrix
+	}
+	m := matrix{}
+	for _, dir := range dirs {
+		m.Include = append(m.Include, matrixEntry{Dir: dir.Path, Exist: dir.Exist, Labels: dir.Labels})
+	}
+	return m
+}
+
+// writeGitHubOutputValue writes a value in the multiline syntax with a random
+// delimiter so that the value can contain any lines.
+func writeGitHubOutputValue(w io.Writer, name, value string) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	delimiter := "ghadelimiter_" + hex.EncodeToString(b)
+	_, err := fmt.Fprintf(w, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+	return err
+}
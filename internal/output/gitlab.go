@@ -0,0 +1,69 @@
+package output
+
+import (
+	"io"
+	"strings"
+
+	"github.com/b4b4r07/changed-objects/internal/detect"
+	"gopkg.in/yaml.v3"
+)
+
+// writeGitLab writes a GitLab child pipeline which has a job per dir. Each job
+// is built from the job template with DIR and EXIST variables.
+// https://docs.gitlab.com/ee/ci/pipelines/downstream_pipelines.html#dynamic-child-pipelines
+func writeGitLab(w io.Writer, diff detect.Diff, jobTemplate string) error {
+	job, err := parseJobTemplate(jobTemplate)
+	if err != nil {
+		return err
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, dir := range diff.Dirs {
+		doc.Content = append(doc.Content,
+			stringNode(jobName(dir.Path)),
+			withVars(job, "variables", dirVars(dir)),
+		)
+	}
+
+	if len(diff.Dirs) == 0 {
+		// a child pipeline without jobs fails to be created
+		noop := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			stringNode("script"),
+			{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{stringNode("echo No changes")}},
+		}}
+		doc.Content = append(doc.Content, stringNode("no-changes"), noop)
+	}
+
+	return encodeYAML(w, doc)
+}
+
+// keywords are global keywords of GitLab CI which can't be used as job names,
+// and "pages" which is a special job to deploy GitLab Pages.
+var keywords = map[string]bool{
+	"default":       true,
+	"include":       true,
+	"stages":        true,
+	"variables":     true,
+	"workflow":      true,
+	"image":         true,
+	"services":      true,
+	"cache":         true,
+	"before_script": true,
+	"after_script":  true,
+	"pages":         true,
+}
+
+// jobName returns the job name for the dir. Dirs starting with "." like the
+// root dir can't be used as they are because GitLab hides such jobs.
+func jobName(path string) string {
+	switch {
+	case path == ".":
+		return "root"
+	case strings.HasPrefix(path, "."):
+		return "/" + path
+	case keywords[path]:
+		return path + "/"
+	default:
+		return path
+	}
+}
@@ -0,0 +1,195 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/b4b4r07/changed-objects/internal/detect"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats are the supported output formats.
+var Formats = []string{"json", "ndjson", "yaml", "csv", "text", "nul", "gitlab", "buildkite", "circleci", "circleci-parameters", "atlantis"}
+
+type Option struct {
+	// Format is one of Formats
+	Format string
+	// Select is one of all, files, dirs and affected
+	Select string
+	// Template is text/template to render the diff with instead of Format
+	Template string
+	// JobTemplate is YAML of a job to generate CI pipelines with
+	JobTemplate string
+	// Atlantis is YAML of settings to generate Atlantis projects with
+	Atlantis string
+}
+
+// Write writes the selected part of the diff to w in the given format.
+func Write(w io.Writer, diff detect.Diff, opt Option) error {
+	if len(opt.Template) > 0 {
+		return writeTemplate(w, diff, opt.Template)
+	}
+
+	switch opt.Format {
+	case "", "json":
+		return json.NewEncoder(w).Encode(selected(diff, opt.Select))
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(selected(diff, opt.Select)); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "ndjson":
+		return writeNDJSON(w, diff, opt.Select)
+	case "csv":
+		return writeCSV(w, diff, opt.Select)
+	case "text":
+		return writePaths(w, diff, opt.Select, '\n')
+	case "nul":
+		return writePaths(w, diff, opt.Select, 0)
+	case "gitlab":
+		return writeGitLab(w, diff, opt.JobTemplate)
+	case "buildkite":
+		return writeBuildkite(w, diff, opt.JobTemplate)
+	case "circleci":
+		return writeCircleCI(w, diff, opt.JobTemplate)
+	case "circleci-parameters":
+		return writeCircleCIParameters(w, diff)
+	case "atlantis":
+		return writeAtlantis(w, diff, opt.Atlantis)
+	default:
+		return fmt.Errorf("%s: unsupported format", opt.Format)
+	}
+}
+
+// selected returns the whole diff for "all", otherwise the list of files,
+// dirs or affected packages. The lists are never nil so that they are encoded
+// as empty lists.
+func selected(diff detect.Diff, sel string) interface{} {
+	switch sel {
+	case "files":
+		if diff.Files == nil {
+			return []detect.File{}
+		}
+		return diff.Files
+	case "dirs":
+		if diff.Dirs == nil {
+			return []detect.Dir{}
+		}
+		return diff.Dirs
+	case "affected":
+		if diff.Affected == nil {
+			return []detect.Package{}
+		}
+		return diff.Affected
+	default:
+		return diff
+	}
+}
+
+func showFiles(sel string) bool {
+	return sel != "dirs" && sel != "affected"
+}
+
+func showDirs(sel string) bool {
+	return sel != "files" && sel != "affected"
+}
+
+func showAffected(sel string) bool {
+	return sel != "files" && sel != "dirs"
+}
+
+// writeNDJSON writes a file, a dir or an affected package as a JSON object
+// per line in this order.
+func writeNDJSON(w io.Writer, diff detect.Diff, sel string) error {
+	enc := json.NewEncoder(w)
+	if showFiles(sel) {
+		for _, file := range diff.Files {
+			if err := enc.Encode(file); err != nil {
+				return err
+			}
+		}
+	}
+	if showDirs(sel) {
+		for _, dir := range diff.Dirs {
+			if err := enc.Encode(dir); err != nil {
+				return err
+			}
+		}
+	}
+	if showAffected(sel) {
+		for _, pkg := range diff.Affected {
+			if err := enc.Encode(pkg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeCSV writes files, dirs and affected packages in the same columns. For
+// a file, exist is the existence of its parent dir. For a package, path is
+// the import path.
+func writeCSV(w io.Writer, diff detect.Diff, sel string) error {
+	cw := csv.NewWriter(w)
+	records := [][]string{{"kind", "path", "type", "exist", "old_path"}}
+	if showFiles(sel) {
+		for _, file := range diff.Files {
+			records = append(records, []string{
+				"file",
+				file.Path,
+				file.Type.String(),
+				strconv.FormatBool(file.ParentDir.Exist),
+				file.OldPath,
+			})
+		}
+	}
+	if showDirs(sel) {
+		for _, dir := range diff.Dirs {
+			records = append(records, []string{
+				"dir",
+				dir.Path,
+				"",
+				strconv.FormatBool(dir.Exist),
+				"",
+			})
+		}
+	}
+	if showAffected(sel) {
+		for _, pkg := range diff.Affected {
+			records = append(records, []string{"package", pkg.ImportPath, "", "", ""})
+		}
+	}
+	return cw.WriteAll(records)
+}
+
+// writePaths writes only paths (import paths for affected packages), each of
+// which is terminated by sep.
+func writePaths(w io.Writer, diff detect.Diff, sel string, sep byte) error {
+	var paths []string
+	if showFiles(sel) {
+		for _, file := range diff.Files {
+			paths = append(paths, file.Path)
+		}
+	}
+	if showDirs(sel) {
+		for _, dir := range diff.Dirs {
+			paths = append(paths, dir.Path)
+		}
+	}
+	if showAffected(sel) {
+		for _, pkg := range diff.Affected {
+			paths = append(paths, pkg.ImportPath)
+		}
+	}
+	for _, path := range paths {
+		if _, err := io.WriteString(w, path+string(sep)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
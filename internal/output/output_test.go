@@ -0,0 +1,286 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/b4b4r07/changed-objects/internal/detect"
+	"github.com/b4b4r07/changed-objects/internal/git"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWrite(t *testing.T) {
+	diff := detect.Diff{
+		Files: []detect.File{
+			{Name: "a.tf", Path: "terraform/a/a.tf", Type: git.Addition, ParentDir: detect.ParentDir{Path: "terraform/a", Exist: true}},
+			{Name: "b.tf", Path: "terraform/b/b.tf", Type: git.Deletion, ParentDir: detect.ParentDir{Path: "terraform/b", Exist: false}},
+		},
+		Dirs: []detect.Dir{
+			{Path: "terraform/a", Exist: true},
+			{Path: "terraform/b", Exist: false},
+		},
+		Affected: []detect.Package{
+			{ImportPath: "example.com/cmd/api", Dir: "cmd/api", Main: true, Reason: "imports changed package example.com/auth"},
+		},
+	}
+
+	cases := []struct {
+		name string
+		opt  Option
+		want string
+	}{
+		{
+			name: "text: all",
+			opt:  Option{Format: "text", Select: "all"},
+			want: "terraform/a/a.tf\nterraform/b/b.tf\nterraform/a\nterraform/b\nexample.com/cmd/api\n",
+		},
+		{
+			name: "text: dirs",
+			opt:  Option{Format: "text", Select: "dirs"},
+			want: "terraform/a\nterraform/b\n",
+		},
+		{
+			name: "nul: files",
+			opt:  Option{Format: "nul", Select: "files"},
+			want: "terraform/a/a.tf\x00terraform/b/b.tf\x00",
+		},
+		{
+			name: "csv: all",
+			opt:  Option{Format: "csv", Select: "all"},
+			want: "kind,path,type,exist,old_path\n" +
+				"file,terraform/a/a.tf,added,true,\n" +
+				"file,terraform/b/b.tf,deleted,false,\n" +
+				"dir,terraform/a,,true,\n" +
+				"dir,terraform/b,,false,\n" +
+				"package,example.com/cmd/api,,,\n",
+		},
+		{
+			name: "ndjson: affected",
+			opt:  Option{Format: "ndjson", Select: "affected"},
+			want: `{"import_path":"example.com/cmd/api","dir":"cmd/api","main":true,"reason":"imports changed package example.com/auth"}` + "\n",
+		},
+		{
+			name: "ndjson: files",
+			opt:  Option{Format: "ndjson", Select: "files"},
+			want: `{"name":"a.tf","path":"terraform/a/a.tf","type":"added","parent_dir":{"path":"terraform/a","exist":true}}` + "\n" +
+				`{"name":"b.tf","path":"terraform/b/b.tf","type":"deleted","parent_dir":{"path":"terraform/b","exist":false}}` + "\n",
+		},
+		{
+			name: "yaml: dirs",
+			opt:  Option{Format: "yaml", Select: "dirs"},
+			want: "- path: terraform/a\n  exist: true\n  files: []\n- path: terraform/b\n  exist: false\n  files: []\n",
+		},
+		{
+			name: "template",
+			opt:  Option{Template: `{{ join "," (paths .Dirs) }} {{ range .Files }}{{ trimPrefix "terraform/" .Path | dir }}={{ toJson .ParentDir.Exist }} {{ end }}`},
+			want: "terraform/a,terraform/b a=true b=false ",
+		},
+		{
+			name: "gitlab",
+			opt:  Option{Format: "gitlab", JobTemplate: "stage: plan\nvariables:\n  TF_IN_AUTOMATION: \"1\"\nscript:\n  - terraform plan\n"},
+			want: "terraform/a:\n  stage: plan\n  variables:\n    TF_IN_AUTOMATION: \"1\"\n    DIR: \"terraform/a\"\n    EXIST: \"true\"\n  script:\n    - terraform plan\n" +
+				"terraform/b:\n  stage: plan\n  variables:\n    TF_IN_AUTOMATION: \"1\"\n    DIR: \"terraform/b\"\n    EXIST: \"false\"\n  script:\n    - terraform plan\n",
+		},
+		{
+			name: "buildkite",
+			opt:  Option{Format: "buildkite", JobTemplate: "command: terraform plan\n"},
+			want: "steps:\n" +
+				"  - label: terraform/a\n    command: terraform plan\n    env:\n      DIR: \"terraform/a\"\n      EXIST: \"true\"\n" +
+				"  - label: terraform/b\n    command: terraform plan\n    env:\n      DIR: \"terraform/b\"\n      EXIST: \"false\"\n",
+		},
+		{
+			name: "circleci-parameters",
+			opt:  Option{Format: "circleci-parameters"},
+			want: `{"dirs":"[\"terraform/a\",\"terraform/b\"]","has_changes":true}` + "\n",
+		},
+		{
+			name: "atlantis",
+			opt: Option{Format: "atlantis", Atlantis: `projects:
+  - pattern: terraform/*
+    workflow: terraform
+    when_modified: ["*.tf"]
+    apply_requirements: [approved]
+`},
+			want: `version: 3
+projects:
+  - name: terraform/a
+    dir: terraform/a
+    workflow: terraform
+    autoplan:
+      when_modified:
+        - '*.tf'
+      enabled: true
+    apply_requirements:
+      - approved
+  - name: terraform/b
+    dir: terraform/b
+    workflow: destroy
+    autoplan:
+      when_modified:
+        - '*.tf'
+      enabled: true
+    apply_requirements:
+      - approved
+`,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			if err := Write(&buf, diff, tt.opt); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(buf.String(), tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_circleCIJobName(t *testing.T) {
+	used := make(map[string]bool)
+	for _, tt := range []struct{ path, want string }{
+		{"terraform/a", "terraform-a"},
+		{"terraform-a", "terraform-a-2"},
+		{".", "root"},
+		{"k8s/a.b/c", "k8s-a-b-c"},
+	} {
+		if got := circleCIJobName(tt.path, used); got != tt.want {
+			t.Errorf("circleCIJobName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func Test_writeGitHubOutput(t *testing.T) {
+	cases := []struct {
+		name string
+		diff detect.Diff
+		want map[string]string
+	}{
+		{
+			name: "dirs",
+			diff: detect.Diff{
+				Files: []detect.File{{Name: "a.tf", Path: "terraform/a/a.tf", Type: git.Addition}},
+				Dirs:  []detect.Dir{{Path: "terraform/a", Exist: true}},
+			},
+			want: map[string]string{
+				"matrix":      `{"include":[{"dir":"terraform/a","exist":true}]}`,
+				"has_changes": "true",
+				"files":       `["terraform/a/a.tf"]`,
+				"dirs":        `["terraform/a"]`,
+			},
+		},
+		{
+			name: "files without dirs",
+			diff: detect.Diff{
+				Files: []detect.File{{Name: "README.md", Path: "README.md", Type: git.Modification}},
+			},
+			want: map[string]string{
+				"matrix":      `{"include":[{"dir":"","exist":false}]}`,
+				"has_changes": "false",
+				"files":       `["README.md"]`,
+				"dirs":        `[]`,
+			},
+		},
+	}
+
+	output := regexp.MustCompile(`(?m)^([a-z_]+)<<(ghadelimiter_[0-9a-f]{32})\n(.*)\n(ghadelimiter_[0-9a-f]{32})\n`)
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			if err := writeGitHubOutput(&buf, tt.diff); err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, m := range output.FindAllStringSubmatch(buf.String(), -1) {
+				if m[2] != m[4] {
+					t.Errorf("delimiters of %s don't match: %s, %s", m[1], m[2], m[4])
+				}
+				got[m[1]] = m[3]
+			}
+			if n := len(output.ReplaceAllString(buf.String(), "")); n != 0 {
+				t.Errorf("unexpected output: %q", buf.String())
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_writeCircleCIParameters(t *testing.T) {
+	var buf bytes.Buffer
+	diff := detect.Diff{
+		Files: []detect.File{{Name: "README.md", Path: "README.md", Type: git.Modification}},
+	}
+	if err := writeCircleCIParameters(&buf, diff); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"dirs":"[]","has_changes":false}` + "\n"
+	if diff := cmp.Diff(buf.String(), want); diff != "" {
+		t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func Test_jobName(t *testing.T) {
+	for _, tt := range []struct{ path, want string }{
+		{"terraform/a", "terraform/a"},
+		{".", "root"},
+		{".github/workflows", "/.github/workflows"},
+		{"variables", "variables/"},
+		{"pages", "pages/"},
+		{"pages/docs", "pages/docs"},
+	} {
+		if got := jobName(tt.path); got != tt.want {
+			t.Errorf("jobName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestWrite_formats(t *testing.T) {
+	for _, format := range Formats {
+		err := Write(io.Discard, detect.Diff{}, Option{Format: format, JobTemplate: "script: [make]\n"})
+		if err != nil && strings.HasSuffix(err.Error(), "unsupported format") {
+			t.Errorf("format %s is not supported by Write", format)
+		}
+	}
+}
+
+func Test_writeAtlantis(t *testing.T) {
+	diff := detect.Diff{
+		Dirs: []detect.Dir{
+			{Path: "terraform/a/prod", Exist: true},
+			{Path: "terraform/a/dev", Exist: true},
+		},
+	}
+	config := `projects:
+  - pattern: terraform/{:service}/prod
+    workflow: prod
+  - pattern: terraform/*/{dev,stg}
+    workflow: dev
+`
+	var buf bytes.Buffer
+	if err := writeAtlantis(&buf, diff, config); err != nil {
+		t.Fatal(err)
+	}
+	want := `version: 3
+projects:
+  - name: terraform/a/prod
+    dir: terraform/a/prod
+    workflow: prod
+  - name: terraform/a/dev
+    dir: terraform/a/dev
+    workflow: dev
+`
+	if diff := cmp.Diff(buf.String(), want); diff != "" {
+		t.Errorf("Result is mismatch (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,100 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/b4b4r07/changed-objects/internal/detect"
+	"gopkg.in/yaml.v3"
+)
+
+// parseJobTemplate parses the template of a job or a step of CI pipelines,
+// which must be a YAML mapping.
+func parseJobTemplate(text string) (*yaml.Node, error) {
+	if len(text) == 0 {
+		return nil, errors.New("job template is required")
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		return nil, fmt.Errorf("cannot parse job template: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("job template must be a mapping")
+	}
+	return doc.Content[0], nil
+}
+
+// dirVars returns the variables to pass a dir to a job. Labels are passed as
+// LABEL_<NAME>, e.g. LABEL_SERVICE for {:service}.
+func dirVars(dir detect.Dir) [][2]string {
+	vars := [][2]string{
+		{"DIR", dir.Path},
+		{"EXIST", strconv.FormatBool(dir.Exist)},
+	}
+	names := make([]string, 0, len(dir.Labels))
+	for name := range dir.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key := "LABEL_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		vars = append(vars, [2]string{key, dir.Labels[name]})
+	}
+	return vars
+}
+
+// withVars returns a copy of the job whose mapping at key has vars in
+// addition to the ones in the template.
+func withVars(job *yaml.Node, key string, vars [][2]string) *yaml.Node {
+	varsNode := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, kv := range vars {
+		varsNode.Content = append(varsNode.Content, stringNode(kv[0]), quotedNode(kv[1]))
+	}
+
+	out := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	found := false
+	for i := 0; i+1 < len(job.Content); i += 2 {
+		k, v := job.Content[i], job.Content[i+1]
+		if k.Value == key && v.Kind == yaml.MappingNode {
+			found = true
+			v = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: append(v.Content[:len(v.Content):len(v.Content)], varsNode.Content...)}
+		}
+		out.Content = append(out.Content, k, v)
+	}
+	if !found {
+		out.Content = append(out.Content, stringNode(key), varsNode)
+	}
+	return out
+}
+
+func hasKey(node *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+func stringNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// quotedNode is for values which must stay strings, e.g. "true" or "1".
+func quotedNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s, Style: yaml.DoubleQuotedStyle}
+}
+
+func encodeYAML(w io.Writer, node *yaml.Node) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
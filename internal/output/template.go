@@ -0,0 +1,77 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/b4b4r07/changed-objects/internal/detect"
+)
+
+// funcs are helpers available in templates. They take arguments in the same
+// order as sprig so that the templates look familiar.
+var funcs = template.FuncMap{
+	"join":       join,
+	"paths":      paths,
+	"base":       path.Base,
+	"dir":        path.Dir,
+	"ext":        path.Ext,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"toJson":     toJSON,
+}
+
+// writeTemplate renders the diff with the text/template.
+func writeTemplate(w io.Writer, diff detect.Diff, text string) error {
+	tmpl, err := template.New("template").Funcs(funcs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("cannot parse template: %w", err)
+	}
+	return tmpl.Execute(w, diff)
+}
+
+// join joins the elements of any list with sep.
+func join(sep string, list interface{}) string {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Sprint(list)
+	}
+	s := make([]string, v.Len())
+	for i := range s {
+		s[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(s, sep)
+}
+
+// paths returns the paths of files or dirs.
+func paths(list interface{}) ([]string, error) {
+	var s []string
+	switch list := list.(type) {
+	case []detect.File:
+		for _, file := range list {
+			s = append(s, file.Path)
+		}
+	case []detect.Dir:
+		for _, dir := range list {
+			s = append(s, dir.Path)
+		}
+	default:
+		return nil, fmt.Errorf("paths: unsupported type %T", list)
+	}
+	return s, nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
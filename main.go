@@ -1,15 +1,18 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/b4b4r07/changed-objects/internal/config"
 	"github.com/b4b4r07/changed-objects/internal/detect"
+	"github.com/b4b4r07/changed-objects/internal/output"
 	clilog "github.com/b4b4r07/go-cli-log"
 	"github.com/jessevdk/go-flags"
+	"github.com/samber/lo"
 )
 
 var (
@@ -18,14 +21,40 @@ var (
 )
 
 type Option struct {
-	Version bool `short:"v" long:"version" description:"Show version"`
+	Version bool   `short:"v" long:"version" description:"Show version"`
+	Profile string `long:"profile" description:"Specify a profile in .changed-objects.yaml to take options from, which are overridden by the ones given here"`
 
 	DefaultBranch string   `long:"default-branch" short:"b" description:"Specify default branch name" default:"main"`
 	MergeBase     string   `long:"merge-base" short:"m" description:"Specify a Git reference as good common ancestors as possible for a merge"`
-	Types         []string `long:"type" description:"Specify the type of changed objects" choice:"added" choice:"modified" choice:"deleted"`
+	Remote        string   `long:"remote" short:"r" description:"Specify a remote name to compare with instead of the remote which the current branch tracks or origin"`
+	Base          string   `long:"base" description:"Specify a Git revision to compare from instead of guessing it from the current branch"`
+	Head          string   `long:"head" description:"Specify a Git revision to compare to instead of HEAD"`
+	FindRenames   int      `long:"find-renames" short:"M" description:"Detect renames with a similarity threshold in percent" optional:"yes" optional-value:"50"`
+	FindCopies    int      `long:"find-copies" short:"C" description:"Detect copies as well as renames with a similarity threshold in percent" optional:"yes" optional-value:"50"`
+	Worktree      bool     `long:"worktree" description:"Compare with the working tree including uncommitted changes instead of the head commit"`
+	Staged        bool     `long:"staged" description:"Compare with the index including staged changes instead of the head commit"`
+	Untracked     bool     `long:"untracked" description:"Include untracked files when comparing with the working tree (implies --worktree)"`
+	GitHubEvent   bool     `long:"from-github-event" description:"Take base and head commits from the GitHub Actions event (pull_request, push and merge_group)"`
+	StateFile     string   `long:"state-file" description:"Specify a file to compare from the head commit of the last successful run, and to store the current one"`
+	Types         []string `long:"type" description:"Specify the type of changed objects" choice:"added" choice:"modified" choice:"deleted" choice:"renamed" choice:"copied"`
 	Ignores       []string `long:"ignore" description:"Specify a pattern to skip when showing changed objects"`
+	Includes      []string `long:"include" description:"Specify a gitignore-style pattern of file paths to show, or to skip with \"!\""`
+	Excludes      []string `long:"exclude" description:"Specify a gitignore-style pattern of file paths to skip, or to show with \"!\""`
+	IgnoreFile    string   `long:"ignore-file" description:"Specify a file of gitignore-style patterns to skip instead of .changed-objects-ignore in the repository root"`
 	GroupBy       []string `long:"group-by" description:"Specify a pattern to make into one group when showing changed objects"`
+	GroupByMarker []string `long:"group-by-marker" description:"Specify a marker file such as main.tf or go.mod to group files by the nearest dir having it instead of --group-by"`
+	Groups        []string `long:"group" description:"Specify a named pattern as name=pattern to list the dirs grouped by it under groups in addition to dirs"`
+	Analyzers     []string `long:"analyze" description:"Also show dirs depending on changed files: root modules calling changed local modules (terraform), kustomizations using changed bases, components or files (kustomize) and charts depending on changed local charts (helm)" choice:"terraform" choice:"kustomize" choice:"helm"`
+	GoPackages    bool     `long:"go-packages" description:"Show Go packages and main binaries importing changed packages directly or transitively under affected"`
 	DirExist      string   `long:"dir-exist" description:"Filter objects by state of dir existing" choice:"true" choice:"false" choice:"all" default:"all"`
+	Sort          string   `long:"sort" description:"Specify the order of files and dirs" choice:"path" choice:"type" choice:"depth" default:"path"`
+	Format        string   `long:"format" short:"f" description:"Specify the output format: json, ndjson, yaml, csv, text, nul, gitlab, buildkite, circleci, circleci-parameters or atlantis" default:"json"`
+	Select        string   `long:"select" description:"Specify which part of the result to print" choice:"all" choice:"files" choice:"dirs" choice:"affected" default:"all"`
+	Template      string   `long:"template" short:"t" description:"Specify a Go template to render the result with instead of --format"`
+	TemplateFile  string   `long:"template-file" description:"Specify a file of Go template to render the result with instead of --format"`
+	JobTemplate   string   `long:"job-template" description:"Specify a YAML file of a job to generate CI pipelines with (--format gitlab, buildkite and circleci)"`
+	Atlantis      string   `long:"atlantis-config" description:"Specify a YAML file of settings per pattern to generate Atlantis projects with (--format atlantis)"`
+	GitHubOutput  bool     `long:"github-output" description:"Write matrix, has_changes, files and dirs to step outputs of GitHub Actions as well"`
 }
 
 func main() {
@@ -44,8 +73,9 @@ func run(args []string) error {
 	log.Printf("[INFO] Args: %#v", args)
 
 	var opt Option
-	p := flags.NewParser(&opt, flags.HelpFlag|flags.PassDoubleDash)
-	args, err := p.ParseArgs(args)
+	p := newParser(&opt)
+	cliArgs := args
+	args, err := p.ParseArgs(cliArgs)
 	if err != nil {
 		return err
 	}
@@ -67,13 +97,51 @@ func run(args []string) error {
 	}
 	log.Printf("[INFO] git repo: %s", repo)
 
+	if name := opt.Profile; len(name) > 0 {
+		profileArgs, err := loadProfile(p, filepath.Join(repo, config.FileName), name)
+		if err != nil {
+			return err
+		}
+		log.Printf("[INFO] profile %s: %#v", name, profileArgs)
+		// parse again so that the options given on the command line come last
+		opt = Option{}
+		p = newParser(&opt)
+		args, err = p.ParseArgs(append(profileArgs, cliArgs...))
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+
+	// --format has no choices to keep the help short, so check it before running
+	if !lo.Contains(output.Formats, opt.Format) {
+		return fmt.Errorf("%s: unsupported format", opt.Format)
+	}
+
 	d, err := detect.New(repo, args, detect.Option{
 		DefaultBranch: opt.DefaultBranch,
 		MergeBase:     opt.MergeBase,
+		Remote:        opt.Remote,
+		Base:          opt.Base,
+		Head:          opt.Head,
+		FindRenames:   opt.FindRenames,
+		FindCopies:    opt.FindCopies,
+		Worktree:      opt.Worktree,
+		Staged:        opt.Staged,
+		Untracked:     opt.Untracked,
+		GitHubEvent:   opt.GitHubEvent,
+		StateFile:     opt.StateFile,
 		Ignores:       opt.Ignores,
+		Includes:      opt.Includes,
+		Excludes:      opt.Excludes,
+		IgnoreFile:    opt.IgnoreFile,
 		GroupBy:       opt.GroupBy,
+		GroupByMarker: opt.GroupByMarker,
+		Groups:        opt.Groups,
+		Analyzers:     opt.Analyzers,
+		GoPackages:    opt.GoPackages,
 		Types:         opt.Types,
 		DirExist:      opt.DirExist,
+		Sort:          opt.Sort,
 	})
 	if err != nil {
 		return err
@@ -84,5 +152,87 @@ func run(args []string) error {
 		return err
 	}
 
-	return json.NewEncoder(os.Stdout).Encode(&diff)
+	tmpl := opt.Template
+	if len(opt.TemplateFile) > 0 {
+		if len(opt.Template) > 0 {
+			return errors.New("cannot specify both --template and --template-file")
+		}
+		b, err := os.ReadFile(opt.TemplateFile)
+		if err != nil {
+			return err
+		}
+		tmpl = string(b)
+	}
+
+	var job string
+	if len(opt.JobTemplate) > 0 {
+		b, err := os.ReadFile(opt.JobTemplate)
+		if err != nil {
+			return err
+		}
+		job = string(b)
+	}
+
+	var atlantis string
+	if len(opt.Atlantis) > 0 {
+		b, err := os.ReadFile(opt.Atlantis)
+		if err != nil {
+			return err
+		}
+		atlantis = string(b)
+	}
+
+	err = output.Write(os.Stdout, diff, output.Option{
+		Format:      opt.Format,
+		Select:      opt.Select,
+		Template:    tmpl,
+		JobTemplate: job,
+		Atlantis:    atlantis,
+	})
+	if err != nil {
+		return err
+	}
+
+	if opt.GitHubOutput {
+		path := os.Getenv("GITHUB_OUTPUT")
+		if len(path) == 0 {
+			return errors.New("GITHUB_OUTPUT is not set")
+		}
+		if err := output.WriteGitHubOutput(path, diff); err != nil {
+			return err
+		}
+	}
+
+	return d.SaveState()
+}
+
+func newParser(opt *Option) *flags.Parser {
+	p := flags.NewParser(opt, flags.HelpFlag|flags.PassDoubleDash)
+	p.Usage = "[OPTIONS] [DIR...]"
+	return p
+}
+
+// loadProfile returns the options in the profile as command line arguments.
+// The options already given on the command line are left out so that a list
+// option such as --type given there replaces the one in the profile.
+func loadProfile(p *flags.Parser, path, name string) ([]string, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := cfg.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+	return profile.Args(func(long string) (config.Flag, error) {
+		option := p.FindOptionByLongName(long)
+		if option == nil || long == "profile" || long == "version" {
+			return config.Flag{}, fmt.Errorf("profile %s: %s: unknown option", name, long)
+		}
+		_, isBool := option.Value().(bool)
+		return config.Flag{
+			Bool: isBool,
+			Skip: option.IsSet() && !option.IsSetDefault(),
+		}, nil
+	})
 }